@@ -0,0 +1,56 @@
+/*******************************************************************************
+*  cmd/log-collector/keygen.go
+*
+*  The keygen subcommand generates CURVE key pairs for the collector and its
+*  publishers:
+*
+*      log-collector keygen -out collector
+*
+*  writes collector.key (secret, mode 0600) and collector.pub. Client public
+*  keys are added to the collector's -curve-allow file; the collector's public
+*  key is handed to publishers as their server key.
+*******************************************************************************/
+
+package main
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pebbe/zmq4"
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+func runKeygen(args []string) {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("out", "",
+		"write <out>.key and <out>.pub instead of printing the key pair")
+	_ = fs.Parse(args)
+
+	public, secret, err := zmq4.NewCurveKeypair()
+	if err != nil {
+		log.Fatalf("generate CURVE key pair: %v", err)
+	}
+
+	if *out == "" {
+		fmt.Printf("public: %s\nsecret: %s\n", public, secret)
+		return
+	}
+
+	if err := os.WriteFile(*out+".key", []byte("# protolog CURVE secret key\n"+secret+"\n"), 0o600); err != nil {
+		log.Fatalf("write secret key: %v", err)
+	}
+	if err := os.WriteFile(*out+".pub", []byte(public+"\n"), 0o644); err != nil {
+		log.Fatalf("write public key: %v", err)
+	}
+	fmt.Printf("wrote %s.key and %s.pub\npublic: %s\n", *out, *out, public)
+}
@@ -0,0 +1,106 @@
+/*******************************************************************************
+*  cmd/log-collector/logcontext.go
+*
+*  Context around a stored log, like grep -C across the logs of one host and
+*  service:
+*
+*      /api/logs/1234/context?before=20&after=20
+*
+*  The log ids come from the id field of /api/logs items.
+*******************************************************************************/
+
+package main
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Espeer5/protolog/internal/auth"
+	"github.com/Espeer5/protolog/internal/storage"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+const (
+	// defaultContextLines is the number of logs before and after by default.
+	defaultContextLines = 20
+
+	// maxContextLines bounds the before and after parameters.
+	maxContextLines = 1000
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// contextHandler serves GET /api/logs/{id}/context[?before=N][&after=N]
+// plus the payload rendering parameters of /api/logs. Items are oldest
+// first; index is the position of the log itself.
+func contextHandler(db *sql.DB, h *hub, a *auth.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil || id <= 0 {
+			http.Error(w, "invalid log id", http.StatusBadRequest)
+			return
+		}
+		render, err := renderOptions(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lines := func(name string) (int, error) {
+			s := q.Get(name)
+			if s == "" {
+				return defaultContextLines, nil
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 0 {
+				return 0, errors.New("invalid " + name)
+			}
+			return min(n, maxContextLines), nil
+		}
+		before, err := lines("before")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		after, err := lines("after")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Denied logs look missing rather than forbidden.
+		rows, index, err := storage.QueryLogContext(db, id, before, after, storageGrants(viewFor(a, r)))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "log not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		out := make([]wsLogMessage, 0, len(rows))
+		for _, row := range rows {
+			out = append(out, h.rowToWSLog(row, render))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"items": out,
+			"index": index,
+		})
+	}
+}
@@ -14,24 +14,37 @@ package main
 *******************************************************************************/
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/pebbe/zmq4"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
 
+	"github.com/Espeer5/protolog/internal/auth"
 	"github.com/Espeer5/protolog/internal/config"
+	"github.com/Espeer5/protolog/internal/ingest"
 	"github.com/Espeer5/protolog/internal/memory"
 	"github.com/Espeer5/protolog/internal/registry"
 	"github.com/Espeer5/protolog/internal/storage"
+	"github.com/Espeer5/protolog/internal/tlsutil"
+	"github.com/Espeer5/protolog/pkg/descset"
 	"github.com/Espeer5/protolog/pkg/logproto/logging"
 )
 
@@ -40,6 +53,7 @@ import (
 *******************************************************************************/
 
 type logDTO struct {
+	ID        int64  `json:"id,omitempty"` // stored row id; 0 for live logs
 	Topic     string `json:"topic"`
 	Timestamp string `json:"timestamp"`
 	Level     string `json:"level"`
@@ -47,23 +61,35 @@ type logDTO struct {
 	Service   string `json:"service"`
 	Summary   string `json:"summary"`
 	Type      string `json:"type"`
+	ClientID  string `json:"clientId,omitempty"`
 }
 
 type wsLogMessage struct {
 	logDTO
 	PayloadJSON json.RawMessage `json:"payloadJson,omitempty"`
+	// PayloadHeuristic marks PayloadJSON as a schema-less wire-format
+	// decoding (see registry.DecodeWire) rather than the declared type.
+	PayloadHeuristic bool `json:"payloadHeuristic,omitempty"`
+	// PayloadText holds the payload when a non-JSON format (text or flat)
+	// was requested; PayloadFormat names it.
+	PayloadText   string          `json:"payloadText,omitempty"`
+	PayloadFormat registry.Format `json:"payloadFormat,omitempty"`
 }
 
 type client struct {
-	topic string
-	hub   *hub
-	conn  *websocket.Conn
-	send  chan wsLogMessage
+	topic  string
+	view   *auth.View             // ACL view of the subscriber; nil sees everything
+	render registry.RenderOptions // payload format of this subscription
+	hub    *hub
+	conn   *websocket.Conn
+	send   chan wsLogMessage
 }
 
 type hub struct {
 	buffers  *memory.TopicBuffers
 	registry *registry.Registry
+	redactor *registry.Redactor
+	cache    *registry.RenderCache // history renderings, keyed by row ID
 
 	register   chan *client
 	unregister chan *client
@@ -71,14 +97,41 @@ type hub struct {
 	clients    map[*client]struct{}
 }
 
+// pipeline is the common sink for every ingest source (ZMQ, OTLP, ...).
+type pipeline struct {
+	db      *sql.DB
+	buffers *memory.TopicBuffers
+	hub     *hub
+
+	// scrub applies redaction to envelopes before they are stored.
+	scrub bool
+
+	// series lists the payload fields materialized at ingest; see series.go.
+	series seriesFields
+}
+
+// schemaVersions persists registry descriptor versions in SQLite.
+type schemaVersions struct {
+	db *sql.DB
+}
+
+// endpointList collects repeated -endpoint flags.
+type endpointList []config.Endpoint
+
+// stringList collects a repeated string flag.
+type stringList []string
+
 /*******************************************************************************
 *  FUNCTIONS
 *******************************************************************************/
 
-func newHub(buffers *memory.TopicBuffers, reg *registry.Registry) *hub {
+func newHub(buffers *memory.TopicBuffers, reg *registry.Registry, rd *registry.Redactor,
+	cacheSize int) *hub {
 	return &hub{
 		buffers:    buffers,
 		registry:   reg,
+		redactor:   rd,
+		cache:      registry.NewRenderCache(reg, cacheSize),
 		register:   make(chan *client),
 		unregister: make(chan *client),
 		broadcast:  make(chan *logging.LogEnvelope, 1024),
@@ -98,11 +151,20 @@ func (h *hub) run() {
 				_ = c.conn.Close()
 			}
 		case env := <-h.broadcast:
+			// Render once per distinct payload format, not once per client.
+			rendered := make(map[registry.RenderOptions]wsLogMessage)
 			for c := range h.clients {
 				if c.topic != "" && c.topic != env.GetTopic() {
 					continue
 				}
-				msg := h.toWSLog(env)
+				if !c.view.Allows(env.GetTopic(), env.GetService()) {
+					continue
+				}
+				msg, ok := rendered[c.render]
+				if !ok {
+					msg = h.toWSLog(env, c.render)
+					rendered[c.render] = msg
+				}
 				select {
 				case c.send <- msg:
 				default:
@@ -113,6 +175,140 @@ func (h *hub) run() {
 	}
 }
 
+func (s schemaVersions) SaveVersion(v registry.Version) error {
+	return storage.InsertSchemaVersion(s.db, storage.SchemaVersionRow{
+		TypeName:    v.Type,
+		Digest:      v.Digest,
+		ValidFromMs: v.ValidFromMs,
+		Descriptors: v.Descriptors,
+	})
+}
+
+func (s schemaVersions) LoadVersions() ([]registry.Version, error) {
+	rows, err := storage.LoadSchemaVersions(s.db)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]registry.Version, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, registry.Version{
+			Type:        r.TypeName,
+			Digest:      r.Digest,
+			ValidFromMs: r.ValidFromMs,
+			Descriptors: r.Descriptors,
+		})
+	}
+	return out, nil
+}
+
+func (l *endpointList) String() string {
+	parts := make([]string, 0, len(*l))
+	for _, ep := range *l {
+		parts = append(parts, ep.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (l *endpointList) Set(spec string) error {
+	ep, err := config.ParseEndpoint(spec)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, ep)
+	return nil
+}
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// ingest stores, buffers and broadcasts a single envelope. It is safe for
+// concurrent use by multiple ingest sources.
+func (p *pipeline) ingest(env *logging.LogEnvelope) {
+	p.ingestFrom(env, "")
+}
+
+// ingestFrom is ingest for envelopes from an authenticated ZMQ client.
+func (p *pipeline) ingestFrom(env *logging.LogEnvelope, clientID string) {
+	p.hub.registry.NoteFingerprint(env.GetSchemaFingerprint(), env.GetType())
+	env.Summary = p.hub.registry.FillSummary(env.GetType(), env.GetPayload(), env.GetSummary())
+
+	if p.scrub {
+		p.redact(env)
+	}
+
+	if id, err := storage.InsertLogFromID(p.db, env, clientID); err != nil {
+		log.Printf("Failed to insert log: %v", err)
+	} else {
+		p.materialize(id, env)
+	}
+
+	// Store in in-memory ring buffer for quick recent-access
+	p.buffers.Add(env)
+
+	p.hub.broadcast <- env
+
+	t := time.Unix(0, 0)
+	if ts := env.GetTimestamp(); ts != nil {
+		t = ts.AsTime()
+	}
+
+	fmt.Printf("[%s] topic=%q level=%s host=%s service=%s pid=%d type=%s client=%s\n  summary=%s\n\n",
+		t.Format(time.RFC3339Nano),
+		env.GetTopic(),
+		env.GetLevel().String(),
+		env.GetHost(),
+		env.GetService(),
+		env.GetPid(),
+		env.GetType(),
+		clientID,
+		env.GetSummary(),
+	)
+}
+
+// learnSchema registers a descriptor set pushed by a publisher. Sets from
+// clients that did not authenticate with CURVE are ignored.
+func (p *pipeline) learnSchema(set []byte, clientID string) {
+	if clientID == "" {
+		log.Printf("ignored descriptor set %s from an unauthenticated client", descset.Fingerprint(set))
+		return
+	}
+	res, learned, err := p.hub.registry.Learn(set)
+	if err != nil {
+		log.Printf("rejected descriptor set %s from %q: %v", descset.Fingerprint(set), clientID, err)
+		return
+	}
+	if learned {
+		log.Printf("learned descriptor set %s from %q: added %v, changed %v",
+			descset.Fingerprint(set), clientID, res.Added, res.Changed)
+	}
+}
+
+// redact scrubs the summary and payload of env in place. Payloads of an
+// unknown type are kept as they are; payloads of a known type that cannot
+// be decoded are dropped, keeping the rest of the envelope.
+func (p *pipeline) redact(env *logging.LogEnvelope) {
+	env.Summary = p.hub.redactor.String(env.GetSummary())
+
+	if p.hub.registry == nil || len(env.GetPayload()) == 0 || env.GetType() == "" {
+		return
+	}
+	b, err := p.hub.registry.Redact(env.GetType(), env.GetPayload())
+	if errors.Is(err, registry.ErrUnknownType) {
+		return
+	}
+	if err != nil {
+		log.Printf("dropping payload of type %q: redaction failed: %v", env.GetType(), err)
+		b = nil
+	}
+	env.Payload = b
+}
+
 func envToDTO(e *logging.LogEnvelope) logDTO {
 	ts := ""
 	if e.GetTimestamp() != nil {
@@ -132,32 +328,82 @@ func envToDTO(e *logging.LogEnvelope) logDTO {
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		// for now, allow all origins; tighten later if needed
-		return true
-	},
+	// Same-host origins only until main installs the -allowed-origin list.
+	CheckOrigin: auth.OriginChecker(nil),
 }
 
-func (h *hub) toWSLog(e *logging.LogEnvelope) wsLogMessage {
+func (h *hub) toWSLog(e *logging.LogEnvelope, o registry.RenderOptions) wsLogMessage {
+	// Live messages are rendered with the schema current now.
+	now := time.Now().UnixMilli()
 	dto := envToDTO(e)
-	var payload json.RawMessage
+	dto.Summary = h.redactor.String(h.registry.DisplaySummary(e.GetType(), e.GetPayload(), now, dto.Summary))
 
-	if h.registry != nil && len(e.GetPayload()) > 0 && e.GetType() != "" {
-		if b, err := h.registry.FormatJSON(e.GetType(), e.GetPayload()); err == nil {
-			payload = json.RawMessage(b)
-		} else {
-			log.Printf("payload decode failed for type %q: %v", e.GetType(), err)
-		}
+	return withPayload(dto, h.registry.RenderPayload(e.GetType(), e.GetPayload(), now, o))
+}
+
+// rowToWSLog renders a stored row with the schema current at its event time.
+func (h *hub) rowToWSLog(r storage.LogRow, o registry.RenderOptions) wsLogMessage {
+	dto := logDTO{
+		ID:        r.ID,
+		Topic:     r.Topic,
+		Timestamp: time.UnixMilli(r.EventTSMs).UTC().Format(time.RFC3339Nano),
+		Level:     logging.LogLevel(r.Level).String(),
+		Service:   r.Service.String,
+		Host:      r.Host.String,
+		Summary:   h.redactor.String(r.Summary.String),
+		Type:      r.Type.String,
+		ClientID:  r.ClientID.String,
+	}
+	if s := h.registry.DisplaySummary(dto.Type, r.Payload, r.EventTSMs, dto.Summary); s != dto.Summary {
+		dto.Summary = h.redactor.String(s)
 	}
 
-	return wsLogMessage{
-		logDTO:      dto,
-		PayloadJSON: payload,
+	return withPayload(dto, h.cache.Render(r.ID, dto.Type, r.Payload, r.EventTSMs, o))
+}
+
+// withPayload combines dto with a rendered payload.
+func withPayload(dto logDTO, p registry.Rendered) wsLogMessage {
+	msg := wsLogMessage{logDTO: dto, PayloadHeuristic: p.Heuristic}
+	switch {
+	case p.Data == nil:
+	case p.Format == registry.RenderJSON:
+		msg.PayloadJSON = json.RawMessage(p.Data)
+	default:
+		msg.PayloadText, msg.PayloadFormat = string(p.Data), p.Format
 	}
+	return msg
 }
 
-func (h *hub) serveWS(w http.ResponseWriter, r *http.Request) {
+// renderOptions parses the payload rendering query parameters: format
+// (json, text or flat) and the booleans emit_defaults, enums_as_numbers and
+// camel_case.
+func renderOptions(q url.Values) (registry.RenderOptions, error) {
+	var o registry.RenderOptions
+	var err error
+	if o.Format, err = registry.ParseFormat(q.Get("format")); err != nil {
+		return o, err
+	}
+	for name, dst := range map[string]*bool{
+		"emit_defaults":    &o.EmitDefaults,
+		"enums_as_numbers": &o.EnumsAsNumbers,
+		"camel_case":       &o.CamelCase,
+	} {
+		if v := q.Get(name); v != "" {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				return o, fmt.Errorf("invalid %s: %q", name, v)
+			}
+		}
+	}
+	return o, nil
+}
+
+func (h *hub) serveWS(w http.ResponseWriter, r *http.Request, view *auth.View) {
 	topic := r.URL.Query().Get("topic") // empty means "all topics"
+	render, err := renderOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -166,17 +412,21 @@ func (h *hub) serveWS(w http.ResponseWriter, r *http.Request) {
 	}
 
 	c := &client{
-		topic: topic,
-		hub:   h,
-		conn:  conn,
-		send:  make(chan wsLogMessage, 256),
+		topic:  topic,
+		view:   view,
+		render: render,
+		hub:    h,
+		conn:   conn,
+		send:   make(chan wsLogMessage, 256),
 	}
 
 	// send recent history first
 	if topic != "" {
 		recent := h.buffers.Recent(topic, 50)
 		for _, e := range recent {
-			c.send <- h.toWSLog(e)
+			if view.Allows(e.GetTopic(), e.GetService()) {
+				c.send <- h.toWSLog(e, render)
+			}
 		}
 	}
 
@@ -205,13 +455,187 @@ func (h *hub) serveWS(w http.ResponseWriter, r *http.Request) {
 	}()
 }
 
+// viewFor returns the ACL view of the principal authenticated for r.
+func viewFor(a *auth.Auth, r *http.Request) *auth.View {
+	p, _ := auth.FromContext(r.Context())
+	return a.ViewFor(p)
+}
+
+// storageGrants converts an ACL view into SQL-side grants; nil for an
+// unrestricted view.
+func storageGrants(v *auth.View) []storage.Grant {
+	grants := v.Grants()
+	if grants == nil {
+		return nil
+	}
+	out := make([]storage.Grant, 0, len(grants))
+	for _, g := range grants {
+		out = append(out, storage.Grant{Topics: g.Topics, Services: g.Services})
+	}
+	return out
+}
+
+// maxSchemaUpload bounds the size of an uploaded FileDescriptorSet.
+const maxSchemaUpload = 16 << 20
+
+// schemaSetName derives an upload name from a .proto file name, e.g.
+// "billing/v1/invoice.proto" becomes "billing_v1_invoice".
+func schemaSetName(file string) string {
+	base := strings.TrimSuffix(file, ".proto")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, base)
+}
+
+// readSchemaSet reads a FileDescriptorSet request body: binary, or protojson
+// with Content-Type application/json. On failure it replies 400 and returns
+// false.
+func readSchemaSet(w http.ResponseWriter, r *http.Request) (*descriptorpb.FileDescriptorSet, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSchemaUpload))
+	if err != nil {
+		http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err = protojson.Unmarshal(body, &set)
+	} else {
+		err = proto.Unmarshal(body, &set)
+	}
+	if err != nil {
+		http.Error(w, "invalid FileDescriptorSet: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &set, true
+}
+
+// parseTime parses an RFC 3339 timestamp, with or without fractional
+// seconds.
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, s)
+	}
+	return t, err
+}
+
+// parseLogFilter reads the time range (start and end, RFC 3339; both
+// required) and the multi-value filters shared by the log queries:
+// repeated topic, service, host, type and level parameters, e.g.
+// ?topic=a&topic=b&level=ERROR. Paging is left to the caller.
+func parseLogFilter(q url.Values) (storage.LogFilter, error) {
+	startStr := q.Get("start")
+	endStr := q.Get("end")
+	if startStr == "" || endStr == "" {
+		return storage.LogFilter{}, errors.New("missing start or end")
+	}
+
+	startT, err := parseTime(startStr)
+	if err != nil {
+		return storage.LogFilter{}, errors.New("invalid start time")
+	}
+	endT, err := parseTime(endStr)
+	if err != nil {
+		return storage.LogFilter{}, errors.New("invalid end time")
+	}
+
+	// Levels: allow repeated level=LOG_LEVEL_INFO, level=INFO or level=2
+	levels := make([]int, 0, len(q["level"]))
+	for _, s := range q["level"] {
+		if s == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(s); err == nil {
+			levels = append(levels, n)
+			continue
+		}
+		if v, ok := logging.LogLevel_value[s]; ok {
+			levels = append(levels, int(v))
+		} else if v, ok := logging.LogLevel_value["LOG_LEVEL_"+strings.ToUpper(s)]; ok {
+			levels = append(levels, int(v))
+		}
+	}
+
+	return storage.LogFilter{
+		StartMs:  startT.UnixMilli(),
+		EndMs:    endT.UnixMilli(),
+		Topics:   q["topic"],
+		Services: q["service"],
+		Hosts:    q["host"],
+		Types:    q["type"],
+		Levels:   levels,
+	}, nil
+}
+
+// parsePaging reads the order (asc or desc, oldest or newest first) and at
+// most one cursor of a log query into f. Cursors are "eventTsMs:id": before
+// and after page to older or newer rows, and cursor (next_cursor) continues
+// in the order of the listing.
+func parsePaging(q url.Values, f *storage.LogFilter) error {
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		return fmt.Errorf("invalid order %q", q.Get("order"))
+	}
+
+	var c string
+	n := 0
+	for _, name := range []string{"cursor", "before", "after"} {
+		if v := q.Get(name); v != "" {
+			c = v
+			n++
+			// cursor pages towards older rows when listing newest first
+			f.Before = name == "before" || (name == "cursor" && f.Desc)
+		}
+	}
+	switch {
+	case n == 0:
+		return nil
+	case n > 1:
+		return errors.New("want at most one of cursor, before and after")
+	}
+
+	ts, id, ok := strings.Cut(c, ":")
+	var err1, err2 error
+	if ok {
+		f.CursorTS, err1 = strconv.ParseInt(ts, 10, 64)
+		f.CursorID, err2 = strconv.ParseInt(id, 10, 64)
+	}
+	if !ok || err1 != nil || err2 != nil || f.CursorID <= 0 {
+		return fmt.Errorf("invalid cursor %q", c)
+	}
+	return nil
+}
+
+// formatCursor returns the paging cursor of r.
+func formatCursor(r storage.LogRow) string {
+	return fmt.Sprintf("%d:%d", r.EventTSMs, r.ID)
+}
+
 func startHTTPServer(httpAddr string, buffers *memory.TopicBuffers, h *hub,
-	                 db *sql.DB) {
+	                 db *sql.DB, p *pipeline, subs []*ingest.Subscriber,
+	                 a *auth.Auth, tlsCfg *tls.Config) {
 	mux := http.NewServeMux()
 
+	// OTLP/HTTP log exports (protobuf encoding)
+	mux.Handle("/v1/logs", a.Require(auth.ScopeIngest, ingest.OTLPHandler(p.ingest)))
+
 	// GET /api/topics
-	mux.HandleFunc("/api/topics", func(w http.ResponseWriter, r *http.Request) {
-		topics := buffers.Topics()
+	mux.Handle("/api/topics", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		view := viewFor(a, r)
+		topics := make([]string, 0)
+		for _, t := range buffers.Topics() {
+			if view.AllowsTopic(t) {
+				topics = append(topics, t)
+			}
+		}
 		resp := map[string]any{
 			"topics": topics,
 		}
@@ -219,10 +643,175 @@ func startHTTPServer(httpAddr string, buffers *memory.TopicBuffers, h *hub,
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-	})
+	}))
+
+	// GET /api/schemas: loaded files and their message types
+	mux.Handle("GET /api/schemas", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]any{
+			"files": h.registry.Files(),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+
+	// GET /api/schemas/versions?type=NAME: recorded descriptor versions
+	mux.Handle("GET /api/schemas/versions", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		typeName := r.URL.Query().Get("type")
+		if typeName == "" {
+			http.Error(w, "missing type", http.StatusBadRequest)
+			return
+		}
+		resp := map[string]any{
+			"type":     typeName,
+			"versions": h.registry.Versions(typeName),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+
+	// GET /api/schemas/types/{name}[?at=MS]: field tree of a message type,
+	// optionally as of a past time
+	mux.Handle("GET /api/schemas/types/{name}", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		var (
+			ti  *registry.TypeInfo
+			err error
+		)
+		if at := r.URL.Query().Get("at"); at != "" {
+			ms, perr := strconv.ParseInt(at, 10, 64)
+			if perr != nil {
+				http.Error(w, "invalid at", http.StatusBadRequest)
+				return
+			}
+			ti, err = h.registry.DescribeTypeAt(r.PathValue("name"), ms)
+		} else {
+			ti, err = h.registry.DescribeType(r.PathValue("name"))
+		}
+		switch {
+		case errors.Is(err, registry.ErrUnknownType):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ti); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+
+	// POST /api/schemas?name=SET: upload a FileDescriptorSet (binary, or
+	// protojson with Content-Type application/json)
+	mux.Handle("POST /api/schemas", a.RequireFunc(auth.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		set, ok := readSchemaSet(w, r)
+		if !ok {
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" && len(set.GetFile()) > 0 {
+			name = schemaSetName(set.GetFile()[0].GetName())
+		}
+
+		res, err := h.registry.AddSet(name, set)
+		var incompatible *registry.IncompatibleError
+		if errors.Is(err, registry.ErrUploadsDisabled) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if errors.As(err, &incompatible) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"error":   err.Error(),
+				"changes": incompatible.Changes,
+			})
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"name": name, "reload": res})
+	}))
+
+	// POST /api/schemas/check: report how an upload (same body as POST
+	// /api/schemas) would change the loaded types, without applying it
+	mux.Handle("POST /api/schemas/check", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		set, ok := readSchemaSet(w, r)
+		if !ok {
+			return
+		}
+
+		changes, err := h.registry.CheckSet(set)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if changes == nil {
+			changes = []registry.Change{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"breaking": registry.HasBreaking(changes),
+			"changes":  changes,
+		})
+	}))
+
+	// DELETE /api/schemas/{name}: retire an uploaded set
+	mux.Handle("DELETE /api/schemas/{name}", a.RequireFunc(auth.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.registry.RemoveSet(r.PathValue("name"))
+		switch {
+		case errors.Is(err, registry.ErrSetNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, registry.ErrUploadsDisabled):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"reload": res})
+	}))
+
+	// POST /api/schemas/reload: re-read all descriptor sets now
+	mux.Handle("POST /api/schemas/reload", a.RequireFunc(auth.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		res := h.registry.Reload()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+
+	// GET /api/ingest/stats: per-endpoint ZMQ ingest counters
+	mux.Handle("/api/ingest/stats", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		stats := make([]ingest.EndpointStats, 0, len(subs))
+		for _, sub := range subs {
+			stats = append(stats, sub.Stats())
+		}
+		resp := map[string]any{
+			"endpoints": stats,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
 
 	// Maintain a REST endpoint as well as the WS
-	mux.HandleFunc("/api/logs/recent", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/api/logs/recent", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
 		topic := r.URL.Query().Get("topic")
 		if topic == "" {
 			http.Error(w, "missing topic", http.StatusBadRequest)
@@ -237,10 +826,20 @@ func startHTTPServer(httpAddr string, buffers *memory.TopicBuffers, h *hub,
 			}
 		}
 
-		envs := buffers.Recent(topic, limit)
+		// Denied topics look empty rather than forbidden.
+		view := viewFor(a, r)
+		var envs []*logging.LogEnvelope
+		if view.AllowsTopic(topic) {
+			envs = buffers.Recent(topic, limit)
+		}
 		out := make([]logDTO, 0, len(envs))
 		for _, e := range envs {
-			out = append(out, envToDTO(e))
+			if view.Allows(e.GetTopic(), e.GetService()) {
+				dto := envToDTO(e)
+				dto.Summary = h.redactor.String(h.registry.DisplaySummary(
+					e.GetType(), e.GetPayload(), time.Now().UnixMilli(), dto.Summary))
+				out = append(out, dto)
+			}
 		}
 
 		resp := map[string]any{
@@ -252,72 +851,28 @@ func startHTTPServer(httpAddr string, buffers *memory.TopicBuffers, h *hub,
 		if err := json.NewEncoder(w).Encode(resp); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
-	})
+	}))
 
 	// WebSocket endpoint for live logs
-	mux.HandleFunc("/ws/logs", func(w http.ResponseWriter, r *http.Request) {
-		h.serveWS(w, r)
-	})
+	mux.Handle("/ws/logs", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		h.serveWS(w, r, viewFor(a, r))
+	}))
 
 	// HTTP serves log queries on /api/logs
-	mux.HandleFunc("/api/logs", func(w http.ResponseWriter, r *http.Request) {
+	// ?start=..&end=..[&order=desc][&cursor=|before=|after=TS:ID]
+	mux.Handle("/api/logs", a.RequireFunc(auth.ScopeRead, func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
 
-		startStr := q.Get("start")
-		endStr := q.Get("end")
-		if startStr == "" || endStr == "" {
-			http.Error(w, "missing start or end", http.StatusBadRequest)
-			return
-		}
-
-		startT, err := time.Parse(time.RFC3339Nano, startStr)
+		render, err := renderOptions(q)
 		if err != nil {
-			startT, err = time.Parse(time.RFC3339, startStr)
-			if err != nil {
-				http.Error(w, "invalid start time", http.StatusBadRequest)
-				return
-			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		endT, err := time.Parse(time.RFC3339Nano, endStr)
+		filter, err := parseLogFilter(q)
 		if err != nil {
-			endT, err = time.Parse(time.RFC3339, endStr)
-			if err != nil {
-				http.Error(w, "invalid end time", http.StatusBadRequest)
-				return
-			}
-		}
-
-		startMs := startT.UnixMilli()
-		endMs := endT.UnixMilli()
-
-		// Multi-value filters via repeated query params:
-		// /api/logs?...&topic=a&topic=b&service=x&service=y
-		topics := q["topic"]
-		services := q["service"]
-		hosts := q["host"]
-		types := q["type"]
-
-		// Levels: allow repeated level=LOG_LEVEL_INFO or level=2
-		levelStrs := q["level"]
-		levels := make([]int, 0, len(levelStrs))
-		for _, s := range levelStrs {
-			if s == "" {
-				continue
-			}
-			if n, err := strconv.Atoi(s); err == nil {
-				levels = append(levels, n)
-				continue
-			}
-			if v, ok := logging.LogLevel_value[s]; ok {
-				levels = append(levels, int(v))
-			} else {
-				// Also tolerate "INFO" etc. if you want
-				key := "LOG_LEVEL_" + strings.ToUpper(s)
-				if v, ok := logging.LogLevel_value[key]; ok {
-					levels = append(levels, int(v))
-				}
-			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
 		limit := 500
@@ -330,30 +885,14 @@ func startHTTPServer(httpAddr string, buffers *memory.TopicBuffers, h *hub,
 			limit = 5000
 		}
 
-		// cursor = "eventTsMs:id"
-		var cursorTS, cursorID int64
-		if c := q.Get("cursor"); c != "" {
-			parts := strings.SplitN(c, ":", 2)
-			if len(parts) == 2 {
-				if ts, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
-					if id, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
-						cursorTS, cursorID = ts, id
-					}
-				}
-			}
+		if err := parsePaging(q, &filter); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		rows, err := storage.QueryLogsMulti(
-			db,
-			startMs, endMs,
-			topics,
-			services,
-			hosts,
-			levels,
-			types,
-			cursorTS, cursorID,
-			limit,
-		)
+		filter.Grants = storageGrants(viewFor(a, r))
+		filter.Limit = limit
+		rows, err := storage.QueryLogsFiltered(db, filter)
 		if err != nil {
 			http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -361,82 +900,283 @@ func startHTTPServer(httpAddr string, buffers *memory.TopicBuffers, h *hub,
 
 		out := make([]wsLogMessage, 0, len(rows))
 		for _, r := range rows {
-			dto := logDTO{
-				Topic:   r.Topic,
-				Level:   logging.LogLevel(r.Level).String(),
-				Service: "",
-				Host:    "",
-				Summary: "",
-				Type:    "",
-			}
+			out = append(out, h.rowToWSLog(r, render))
+		}
 
-			dto.Timestamp = time.UnixMilli(r.EventTSMs).UTC().Format(time.RFC3339Nano)
-			if r.Service.Valid { dto.Service = r.Service.String }
-			if r.Host.Valid { dto.Host = r.Host.String }
-			if r.Summary.Valid { dto.Summary = r.Summary.String }
-			if r.Type.Valid { dto.Type = r.Type.String }
-
-			var payload json.RawMessage
-			if h.registry != nil && len(r.Payload) > 0 && dto.Type != "" {
-				if b, err := h.registry.FormatJSON(dto.Type, r.Payload); err == nil {
-					payload = json.RawMessage(b)
-				} else {
-					log.Printf("history payload decode failed for type %q: %v", dto.Type, err)
-				}
+		// Rows older and newer than the page may exist: past a full page
+		// in the direction read, and past the cursor in the other.
+		var beforeCursor, afterCursor string
+		if len(rows) > 0 {
+			oldest, newest := rows[0], rows[len(rows)-1]
+			if filter.Desc {
+				oldest, newest = newest, oldest
+			}
+			scanDesc := filter.Desc
+			if filter.CursorID != 0 {
+				scanDesc = filter.Before
+			}
+			if (scanDesc && len(rows) == limit) || (!scanDesc && filter.CursorID != 0) {
+				beforeCursor = formatCursor(oldest)
+			}
+			if (!scanDesc && len(rows) == limit) || (scanDesc && filter.CursorID != 0) {
+				afterCursor = formatCursor(newest)
 			}
-
-			out = append(out, wsLogMessage{
-				logDTO:      dto,
-				PayloadJSON: payload,
-			})
 		}
-
-		nextCursor := ""
-		if len(rows) == limit {
-			last := rows[len(rows)-1]
-			nextCursor = fmt.Sprintf("%d:%d", last.EventTSMs, last.ID)
+		nextCursor := afterCursor
+		if filter.Desc {
+			nextCursor = beforeCursor
 		}
 
 		resp := map[string]any{
-			"items":       out,
-			"next_cursor": nextCursor,
+			"items":         out,
+			"next_cursor":   nextCursor,
+			"before_cursor": beforeCursor,
+			"after_cursor":  afterCursor,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(resp)
-	})
+	}))
+
+	// GET /api/logs/{id}/context?before=20&after=20: the logs around one on
+	// the same host and service; see logcontext.go
+	mux.Handle("GET /api/logs/{id}/context", a.RequireFunc(auth.ScopeRead, contextHandler(db, h, a)))
+
+	// GET /api/series?type=T&field=PATH&start=..&end=..[&bucket=1m]: numeric
+	// payload field aggregated per time bucket; see series.go
+	mux.Handle("GET /api/series", a.RequireFunc(auth.ScopeRead, seriesHandler(db, p, a)))
+
+	// GET /api/stats/histogram and /api/stats/groupby: log counts per time
+	// bucket or per topic/service/host/type/level; see stats.go
+	mux.Handle("GET /api/stats/histogram", a.RequireFunc(auth.ScopeRead, histogramHandler(db, a)))
+	mux.Handle("GET /api/stats/groupby", a.RequireFunc(auth.ScopeRead, groupByHandler(db, a)))
+
+	// GET /api/facets?start=..&end=..: distinct topics, services, hosts and
+	// types and per-level counts in the range; see stats.go
+	mux.Handle("GET /api/facets", a.RequireFunc(auth.ScopeRead, facetsHandler(db, a)))
 
 	// Static files (GUI) from ./ui/static
 	fs := http.FileServer(http.Dir("protolog/ui/static"))
 	mux.Handle("/", fs)
 
 	go func() {
-		log.Printf("Starting HTTP server on %s", httpAddr)
-		if err := http.ListenAndServe(httpAddr, mux); err != nil {
+		if err := serveHTTP(httpAddr, mux, tlsCfg); err != nil {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 	}()
 }
 
+// serveHTTP serves handler on addr, over TLS when tlsCfg is non-nil.
+func serveHTTP(addr string, handler http.Handler, tlsCfg *tls.Config) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsCfg,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if tlsCfg == nil {
+		log.Printf("Starting HTTP server on %s", addr)
+		return srv.ListenAndServe()
+	}
+	log.Printf("Starting HTTPS server on %s", addr)
+	return srv.ListenAndServeTLS("", "")
+}
+
+// startRedirectServer redirects plain HTTP on addr to HTTPS on httpsAddr.
+func startRedirectServer(addr, httpsAddr string) {
+	go func() {
+		log.Printf("Redirecting HTTP on %s to HTTPS", addr)
+		if err := http.ListenAndServe(addr, tlsutil.RedirectHandler(httpsAddr)); err != nil {
+			log.Fatalf("HTTP redirect server error: %v", err)
+		}
+	}()
+}
+
+// startOTLPServer serves only the OTLP/HTTP logs endpoint on its own address,
+// for exporters configured with the conventional OTLP port.
+func startOTLPServer(addr string, p *pipeline, a *auth.Auth, tlsCfg *tls.Config) {
+	mux := http.NewServeMux()
+	mux.Handle("/v1/logs", a.Require(auth.ScopeIngest, ingest.OTLPHandler(p.ingest)))
+
+	go func() {
+		log.Printf("Starting OTLP/HTTP receiver on %s", addr)
+		if err := serveHTTP(addr, mux, tlsCfg); err != nil {
+			log.Fatalf("OTLP server error: %v", err)
+		}
+	}()
+}
+
+// startSyslogListeners starts the optional syslog receivers; an empty address
+// disables the corresponding transport.
+func startSyslogListeners(udpAddr, tcpAddr string, p *pipeline) {
+	if udpAddr != "" {
+		conn, err := net.ListenPacket("udp", udpAddr)
+		if err != nil {
+			log.Fatalf("failed to listen for syslog on udp %s: %v", udpAddr, err)
+		}
+		go func() {
+			log.Printf("Listening for syslog on udp %s", udpAddr)
+			if err := ingest.ServeSyslogUDP(conn, p.ingest); err != nil {
+				log.Fatalf("syslog udp error: %v", err)
+			}
+		}()
+	}
+
+	if tcpAddr != "" {
+		ln, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			log.Fatalf("failed to listen for syslog on tcp %s: %v", tcpAddr, err)
+		}
+		go func() {
+			log.Printf("Listening for syslog on tcp %s", tcpAddr)
+			if err := ingest.ServeSyslogTCP(ln, p.ingest); err != nil {
+				log.Fatalf("syslog tcp error: %v", err)
+			}
+		}()
+	}
+}
+
 /*******************************************************************************
 *  MAIN EXECUTABLE
 *******************************************************************************/
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "keygen" {
+		runKeygen(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "schema-check" {
+		runSchemaCheck(os.Args[2:])
+		return
+	}
+
 	addr := flag.String("addr", "tcp://*:5556",
-		"ZMQ address to bind SUB socket (publishers should connect here)")
+		"ZMQ address to bind SUB socket (publishers should connect here); "+
+			"see -endpoint for multiple or connect-mode endpoints")
 
 	cfg := config.DefaultConfig()
 
 	dataDir := flag.String("data-dir", config.DefaultDataDir(),
 		"directory to store per-topic log files")
 
+	renderCache := flag.Int("render-cache", 4096,
+		"number of rendered history payloads to cache (0 disables the cache)")
+
 	bufferSize := flag.Int("buffer-size", cfg.BufferSize,
 		"number of recent log messages to keep in memory per topic")
 
 	httpAddr := flag.String("http-addr", ":8080",
 		"HTTP listen address for API and GUI (e.g. :8080)")
 
+	otlpAddr := flag.String("otlp-addr", "",
+		"optional extra listen address for OTLP/HTTP log ingest (e.g. :4318); "+
+			"/v1/logs is always served on -http-addr as well")
+
+	syslogUDP := flag.String("syslog-udp", "",
+		"optional UDP listen address for syslog (RFC 5424/3164), e.g. :514")
+
+	syslogTCP := flag.String("syslog-tcp", "",
+		"optional TCP listen address for syslog (RFC 5424/3164), e.g. :601")
+
+	var endpointFlags endpointList
+	flag.Var(&endpointFlags, "endpoint",
+		"ZMQ SUB endpoint as [bind:|connect:]ADDR[,hwm=N][,curve]; may be repeated "+
+			"(e.g. -endpoint ipc:///tmp/protolog.ipc -endpoint connect:tcp://10.0.0.7:5557)")
+
+	var subscribeFlags stringList
+	flag.Var(&subscribeFlags, "subscribe",
+		"only receive topics with this prefix (matched against the publisher's topic "+
+			"frame before decoding); may be repeated. When set, legacy single-frame "+
+			"envelopes are no longer received")
+
+	curveSecretKey := flag.String("curve-secret-key", "",
+		"file holding the collector's Z85 CURVE secret key (see `log-collector keygen`); "+
+			"required by endpoints with the curve option")
+
+	curveAllow := flag.String("curve-allow", "",
+		"file listing allowed CURVE client public keys, one per line with an optional name")
+
+	authConfig := flag.String("auth-config", "",
+		"JSON file configuring HTTP API authentication (bearer tokens, basic users, OIDC); "+
+			"when unset the API is open to anyone who can reach -http-addr")
+
+	var originFlags stringList
+	flag.Var(&originFlags, "allowed-origin",
+		"extra browser origin allowed to open the WebSocket (e.g. https://logs.example.com); "+
+			"may be repeated. Same-host origins are always allowed")
+
+	tlsCert := flag.String("tls-cert", "",
+		"PEM certificate file; with -tls-key serves -http-addr over HTTPS/WSS. "+
+			"The files are reloaded automatically when they change")
+
+	tlsKey := flag.String("tls-key", "",
+		"PEM private key file for -tls-cert")
+
+	tlsClientCA := flag.String("tls-client-ca", "",
+		"PEM CA bundle; when set, API clients must present a certificate signed by it (mTLS)")
+
+	tlsClientAuth := flag.String("tls-client-auth", "",
+		"mTLS mode with -tls-client-ca: require (default) or optional")
+
+	redirectAddr := flag.String("http-redirect-addr", "",
+		"optional plain-HTTP listen address (e.g. :80) that redirects to HTTPS on -http-addr")
+
+	var schemaFlags stringList
+	flag.Var(&schemaFlags, "schema",
+		"descriptor set file (.desc) or directory of them to load into the schema registry; "+
+			"may be repeated. Replaces the built-in default list")
+
+	var protoDirs stringList
+	flag.Var(&protoDirs, "proto-dir",
+		"directory of .proto sources to compile into the schema registry (file names are "+
+			"relative to it, as with protoc -I); may be repeated")
+
+	var protoPaths stringList
+	flag.Var(&protoPaths, "proto-path",
+		"extra import path for -proto-dir sources; may be repeated")
+
+	schemaWatch := flag.Bool("schema-watch", true,
+		"reload the schema registry when its descriptor files or directories change")
+
+	schemaPush := flag.Bool("schema-push", false,
+		"learn new message types from descriptor sets pushed by publishers on the "+
+			"ZMQ schema side-channel; requires -curve-secret-key and -curve-allow, and "+
+			"only sets pushed by allowed CURVE clients are accepted")
+
+	schemaCompat := flag.String("schema-compat", string(registry.CompatWarn),
+		"how schema reloads and uploads treat breaking changes to loaded types "+
+			"(field numbers reused or retyped, required fields, renumbered fields or enum values): "+
+			"off, warn (log them) or reject (keep the previous version of the files affected)")
+
+	var redactFields stringList
+	flag.Var(&redactFields, "redact-field",
+		"fully qualified payload field to redact (e.g. demo.LoginEvent.password); may be repeated")
+
+	var redactPatterns stringList
+	flag.Var(&redactPatterns, "redact-pattern",
+		"regular expression whose matches are redacted from payload strings and summaries; may be repeated")
+
+	redactSensitive := flag.Bool("redact-sensitive", true,
+		"redact payload fields annotated with (protolog.sensitive) = true")
+
+	redactAtIngest := flag.Bool("redact-at-ingest", false,
+		"also scrub redacted values from payloads and summaries before they are stored")
+
+	var summaryTemplates stringList
+	flag.Var(&summaryTemplates, "summary-template",
+		"TYPE=TEMPLATE: text/template computing the summary of TYPE payloads that arrive "+
+			"without one, e.g. 'demo.Metric={{.name}}={{.value}}{{.unit}}'; may be repeated")
+
+	var summaryOverrides stringList
+	flag.Var(&summaryOverrides, "summary-override",
+		"TYPE=TEMPLATE: like -summary-template, but also replaces producer summaries "+
+			"when displayed (stored summaries are kept); may be repeated")
+
+	var seriesFieldFlags stringList
+	flag.Var(&seriesFieldFlags, "series-field",
+		"TYPE=PATH: numeric payload field to extract into the series table at ingest, "+
+			"e.g. demo.Metric=value, so /api/series need not decode payloads; may be repeated")
+
 	flag.Parse()
 
 	log.Printf("Using data dir: %s", *dataDir)
@@ -445,17 +1185,64 @@ func main() {
 
 	topicBuffers := memory.NewTopicBuffers(*bufferSize)
 
-	// Schema registry
-	reg, err := registry.NewFromFiles(cfg.DescriptorSets)
+	// Schema registry, reloaded whenever its descriptor sets change
+	if len(schemaFlags) > 0 {
+		cfg.DescriptorSets = schemaFlags
+	}
+	reg, _ := registry.Open(cfg.DescriptorSets)
+	if err := reg.EnableUploads(filepath.Join(*dataDir, "schemas")); err != nil {
+		log.Printf("schema uploads disabled: %v", err)
+	}
+	if len(protoDirs) > 0 {
+		reg.SetProtoSources(registry.ProtoSources{Roots: protoDirs, ImportPaths: protoPaths})
+	}
+	// The policy applies to updates after startup; whatever is on disk now
+	// is loaded as is.
+	compat, err := registry.ParseCompatPolicy(*schemaCompat)
 	if err != nil {
-		log.Printf("schema registry disabled (could not load descriptor): %v", err)
-		reg = nil
-	} else {
-		log.Printf("Loaded schema registry")
+		log.Fatalf("invalid -schema-compat: %v", err)
 	}
+	reg.SetCompatPolicy(compat)
+	if *schemaWatch {
+		if err := reg.Watch(); err != nil {
+			log.Printf("schema registry: not watching for changes: %v", err)
+		}
+		defer reg.Close()
+	}
+
+	// Redaction of sensitive payload data
+	redactor, err := registry.NewRedactor(registry.RedactionRules{
+		Fields:                redactFields,
+		Patterns:              redactPatterns,
+		IgnoreSensitiveOption: !*redactSensitive,
+	})
+	if err != nil {
+		log.Fatalf("invalid redaction rules: %v", err)
+	}
+	reg.SetRedactor(redactor)
+
+	// Summary templates
+	var summaryDefs []registry.SummaryTemplate
+	for _, list := range []struct {
+		defs     []string
+		override bool
+	}{{summaryTemplates, false}, {summaryOverrides, true}} {
+		for _, def := range list.defs {
+			st, err := registry.ParseSummaryTemplate(def, list.override)
+			if err != nil {
+				log.Fatalf("invalid summary template: %v", err)
+			}
+			summaryDefs = append(summaryDefs, st)
+		}
+	}
+	summaries, err := registry.NewSummaryTemplates(summaryDefs)
+	if err != nil {
+		log.Fatalf("invalid summary template: %v", err)
+	}
+	reg.SetSummaryTemplates(summaries)
 
 	// WebSocket hub
-	h := newHub(topicBuffers, reg)
+	h := newHub(topicBuffers, reg, redactor, *renderCache)
 	go h.run()
 
 	db, err := storage.OpenSQLite("protolog/data/protolog.db")
@@ -468,61 +1255,132 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// HTTP server (REST + static GUI + WebSockets)
-	startHTTPServer(*httpAddr, topicBuffers, h, db)
+	if err := reg.EnableHistory(schemaVersions{db: db}); err != nil {
+		log.Printf("schema version history disabled: %v", err)
+	}
 
-	sub, err := zmq4.NewSocket(zmq4.SUB)
+	series, err := parseSeriesFields(seriesFieldFlags)
 	if err != nil {
-		log.Fatalf("failed to create SUB socket: %v", err)
+		log.Fatalf("invalid -series-field: %v", err)
+	}
+	for typeName, paths := range series {
+		for _, path := range paths {
+			if err := reg.CheckNumericPath(typeName, path); err != nil {
+				log.Printf("series field %s=%s: %v", typeName, path, err)
+			}
+		}
 	}
-	defer sub.Close()
 
-	if err := sub.SetSubscribe(""); err != nil {
-		log.Fatalf("failed to set SUBSCRIBE: %v", err)
+	p := &pipeline{db: db, buffers: topicBuffers, hub: h, scrub: *redactAtIngest, series: series}
+
+	// ZMQ SUB sockets, one per endpoint. A bare -addr keeps its historical
+	// meaning when no -endpoint is given.
+	endpoints := []config.Endpoint(endpointFlags)
+	addrSet := len(endpoints) == 0
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "addr" {
+			addrSet = true
+		}
+	})
+	if addrSet {
+		endpoints = append([]config.Endpoint{{Addr: *addr, Mode: config.ModeBind}}, endpoints...)
 	}
+	cfg.Endpoints = endpoints
+	cfg.Subscriptions = subscribeFlags
 
-	log.Printf("Binding SUB socket on %s ...", *addr)
-	if err := sub.Bind(*addr); err != nil {
-		log.Fatalf("failed to bind SUB socket: %v", err)
+	subOpts := ingest.SubscriberOptions{Topics: cfg.Subscriptions}
+	if *schemaPush {
+		if *curveSecretKey == "" || *curveAllow == "" {
+			log.Fatalf("-schema-push requires -curve-secret-key and -curve-allow")
+		}
+		subOpts.Schemas = p.learnSchema
 	}
-	log.Printf("Connected. Waiting for log envelopes...")
+	if *curveSecretKey != "" {
+		key, err := ingest.LoadCurveKey(*curveSecretKey)
+		if err != nil {
+			log.Fatalf("failed to load CURVE secret key: %v", err)
+		}
+		subOpts.CurveSecretKey = key
 
-	for {
-		data, err := sub.RecvBytes(0)
+		var allowed map[string]string
+		if *curveAllow != "" {
+			if allowed, err = ingest.LoadCurveAllowList(*curveAllow); err != nil {
+				log.Fatalf("failed to load CURVE allow-list: %v", err)
+			}
+			log.Printf("CURVE: %d client key(s) allowed", len(allowed))
+		} else {
+			log.Printf("CURVE: no -curve-allow list; any client key is accepted (encryption only)")
+		}
+		if err := ingest.StartCurveAuth(allowed); err != nil {
+			log.Fatalf("failed to start CURVE authentication: %v", err)
+		}
+		defer ingest.StopCurveAuth()
+	}
+
+	subs := make([]*ingest.Subscriber, 0, len(cfg.Endpoints))
+	for _, ep := range cfg.Endpoints {
+		log.Printf("Opening SUB socket: %s", ep)
+		sub, err := ingest.NewSubscriber(ep, subOpts)
 		if err != nil {
-			log.Printf("recv error: %v", err)
-			continue
+			log.Fatalf("failed to open SUB socket: %v", err)
 		}
+		defer sub.Close()
+		subs = append(subs, sub)
+	}
 
-		var env logging.LogEnvelope
-		if err := proto.Unmarshal(data, &env); err != nil {
-			log.Printf("failed to unmarshal LogEnvelope: %v", err)
-			continue
+	// HTTP API authentication
+	var authn *auth.Auth
+	if *authConfig != "" {
+		ac, err := auth.LoadConfig(*authConfig)
+		if err != nil {
+			log.Fatalf("failed to load auth config: %v", err)
+		}
+		if authn, err = auth.New(context.Background(), ac); err != nil {
+			log.Fatalf("failed to configure auth: %v", err)
 		}
+		log.Printf("HTTP auth: %d token(s), %d basic user(s), oidc=%v",
+			len(ac.Tokens), len(ac.Basic), ac.OIDC != nil)
+	} else {
+		log.Printf("HTTP auth disabled (no -auth-config)")
+	}
+	upgrader.CheckOrigin = auth.OriginChecker(originFlags)
 
-		if err := storage.InsertLog(db, &env); err != nil {
-			log.Printf("Failed to insert log: %v", err)
+	// TLS for the HTTP/WS server
+	var tlsCfg *tls.Config
+	if *tlsCert != "" || *tlsKey != "" {
+		if *tlsCert == "" || *tlsKey == "" {
+			log.Fatalf("-tls-cert and -tls-key must be given together")
+		}
+		tlsCfg, err = tlsutil.ServerConfig(tlsutil.Options{
+			CertFile:     *tlsCert,
+			KeyFile:      *tlsKey,
+			ClientCAFile: *tlsClientCA,
+			ClientAuth:   *tlsClientAuth,
+		})
+		if err != nil {
+			log.Fatalf("failed to configure TLS: %v", err)
 		}
+	} else if *tlsClientCA != "" || *redirectAddr != "" {
+		log.Fatalf("-tls-client-ca and -http-redirect-addr require -tls-cert and -tls-key")
+	}
 
-		// Store in in-memory ring buffer for quick recent-access
-		topicBuffers.Add(&env)
+	// HTTP server (REST + static GUI + WebSockets + OTLP)
+	startHTTPServer(*httpAddr, topicBuffers, h, db, p, subs, authn, tlsCfg)
 
-		h.broadcast <- &env
+	if *redirectAddr != "" {
+		startRedirectServer(*redirectAddr, *httpAddr)
+	}
 
-		t := time.Unix(0, 0)
-		if ts := env.GetTimestamp(); ts != nil {
-			t = ts.AsTime()
-		}
+	if *otlpAddr != "" {
+		startOTLPServer(*otlpAddr, p, authn, tlsCfg)
+	}
 
-		fmt.Printf("[%s] topic=%q level=%s host=%s service=%s pid=%d type=%s\n  summary=%s\n\n",
-			t.Format(time.RFC3339Nano),
-			env.GetTopic(),
-			env.GetLevel().String(),
-			env.GetHost(),
-			env.GetService(),
-			env.GetPid(),
-			env.GetType(),
-			env.GetSummary(),
-		)
+	startSyslogListeners(*syslogUDP, *syslogTCP, p)
+
+	for _, sub := range subs {
+		go sub.Run(p.ingestFrom)
 	}
+	log.Printf("Waiting for log envelopes...")
+
+	select {}
 }
@@ -0,0 +1,99 @@
+/*******************************************************************************
+*  cmd/log-collector/schemacheck.go
+*
+*  The schema-check subcommand compares two descriptor sets, typically the
+*  deployed build of a schema and a candidate, before the candidate is rolled
+*  out:
+*
+*      log-collector schema-check old.desc new.desc
+*
+*  prints one line per change and exits with status 1 if any change breaks
+*  decoding of existing data (or, with -fail-on warning, on any change).
+*******************************************************************************/
+
+package main
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+func runSchemaCheck(args []string) {
+	fs := flag.NewFlagSet("schema-check", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the changes as a JSON array")
+	failOn := fs.String("fail-on", "breaking",
+		"exit with status 1 on changes of this severity or worse: breaking or warning")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: log-collector schema-check [flags] OLD.desc NEW.desc")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *failOn != string(registry.Breaking) && *failOn != string(registry.Warning) {
+		log.Fatalf("invalid -fail-on %q (want breaking or warning)", *failOn)
+	}
+
+	old, err := readDescriptorSet(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cur, err := readDescriptorSet(fs.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	changes, err := registry.CompareSets(old, cur)
+	if err != nil {
+		log.Fatalf("compare descriptor sets: %v", err)
+	}
+
+	if *asJSON {
+		if changes == nil {
+			changes = []registry.Change{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(changes)
+	} else {
+		for _, c := range changes {
+			fmt.Printf("%s: %s\n", c.Severity, c)
+		}
+		if len(changes) == 0 {
+			fmt.Println("no changes affecting compatibility")
+		}
+	}
+
+	if registry.HasBreaking(changes) || (*failOn == string(registry.Warning) && len(changes) > 0) {
+		os.Exit(1)
+	}
+}
+
+func readDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read descriptor set: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("unmarshal descriptor set %q: %w", path, err)
+	}
+	return &set, nil
+}
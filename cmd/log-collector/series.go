@@ -0,0 +1,223 @@
+/*******************************************************************************
+*  cmd/log-collector/series.go
+*
+*  Numeric time series from payload fields. GET /api/series buckets the values
+*  of one numeric field of one payload type over time:
+*
+*      /api/series?type=demo.Metric&field=value&start=...&end=...&bucket=1m
+*
+*  Fields configured with -series-field are extracted at ingest into the
+*  series_values table and aggregated there in SQL; other fields are decoded
+*  from the stored payloads on each request.
+*******************************************************************************/
+
+package main
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Espeer5/protolog/internal/auth"
+	"github.com/Espeer5/protolog/internal/registry"
+	"github.com/Espeer5/protolog/internal/storage"
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// seriesFields maps payload types to the field paths materialized at ingest.
+type seriesFields map[string][]string
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+const (
+	// defaultSeriesBuckets is the number of buckets when none is requested.
+	defaultSeriesBuckets = 120
+
+	// maxSeriesBuckets bounds the number of buckets in a response.
+	maxSeriesBuckets = 10000
+
+	// maxSeriesScan bounds the rows decoded for a non-materialized field.
+	maxSeriesScan = 200000
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// parseSeriesFields parses "TYPE=PATH" definitions, e.g. "demo.Metric=value".
+func parseSeriesFields(defs []string) (seriesFields, error) {
+	out := make(seriesFields)
+	for _, def := range defs {
+		typeName, path, ok := strings.Cut(def, "=")
+		if !ok || typeName == "" || path == "" {
+			return nil, fmt.Errorf("series field %q: want TYPE=PATH", def)
+		}
+		out[typeName] = append(out[typeName], path)
+	}
+	return out, nil
+}
+
+func (s seriesFields) has(typeName, path string) bool {
+	for _, p := range s[typeName] {
+		if p == path {
+			return true
+		}
+	}
+	return false
+}
+
+// materialize stores the configured series fields of env, logged as row id.
+// Payloads without a value at a path, or with NaN there, are skipped.
+func (p *pipeline) materialize(id int64, env *logging.LogEnvelope) {
+	paths := p.series[env.GetType()]
+	if len(paths) == 0 || len(env.GetPayload()) == 0 {
+		return
+	}
+
+	tsMs := env.GetTimestamp().AsTime().UnixMilli()
+	values := make([]storage.SeriesValue, 0, len(paths))
+	for _, path := range paths {
+		v, err := p.hub.registry.Number(env.GetType(), env.GetPayload(), tsMs, path)
+		if err != nil || math.IsNaN(v) {
+			continue
+		}
+		values = append(values, storage.SeriesValue{Field: path, Value: v})
+	}
+	if err := storage.InsertSeriesValues(p.db, id, values); err != nil {
+		log.Printf("Failed to insert series values: %v", err)
+	}
+}
+
+// parseBucket reads a bucket width given as a duration ("30s", "5m") or in
+// milliseconds; "" picks one for defaultSeriesBuckets buckets.
+func parseBucket(s string, spanMs int64) (int64, error) {
+	if s == "" {
+		return max(1, (spanMs+defaultSeriesBuckets-1)/defaultSeriesBuckets), nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil && ms > 0 {
+		return ms, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= time.Millisecond {
+		return d.Milliseconds(), nil
+	}
+	return 0, fmt.Errorf("invalid bucket %q", s)
+}
+
+// seriesHandler serves GET /api/series?type=T&field=PATH&start=..&end=..
+// [&bucket=1m][&source=decode] plus the filters of /api/logs.
+func seriesHandler(db *sql.DB, p *pipeline, a *auth.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		filter, err := parseLogFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		typeName, field := q.Get("type"), q.Get("field")
+		if typeName == "" || field == "" || len(q["type"]) > 1 {
+			http.Error(w, "want exactly one type and a field", http.StatusBadRequest)
+			return
+		}
+		if filter.EndMs <= filter.StartMs {
+			http.Error(w, "end must be after start", http.StatusBadRequest)
+			return
+		}
+		bucketMs, err := parseBucket(q.Get("bucket"), filter.EndMs-filter.StartMs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if (filter.EndMs-filter.StartMs)/bucketMs >= maxSeriesBuckets {
+			http.Error(w, fmt.Sprintf("more than %d buckets; use a wider bucket", maxSeriesBuckets),
+				http.StatusBadRequest)
+			return
+		}
+		if err := p.hub.registry.CheckNumericPath(typeName, field); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Grants = storageGrants(viewFor(a, r))
+
+		resp := map[string]any{
+			"type":      typeName,
+			"field":     field,
+			"bucket_ms": bucketMs,
+		}
+
+		if p.series.has(typeName, field) && q.Get("source") != "decode" {
+			buckets, err := storage.QuerySeries(db, field, filter, bucketMs)
+			if err != nil {
+				http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if buckets == nil {
+				buckets = []storage.SeriesBucket{}
+			}
+			resp["source"] = "table"
+			resp["buckets"] = buckets
+		} else {
+			agg := storage.SeriesAggregator{StartMs: filter.StartMs, EndMs: filter.EndMs, BucketMs: bucketMs}
+			scanned, truncated, err := decodeSeries(db, p.hub.registry, typeName, field, filter, &agg)
+			if err != nil {
+				http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			resp["source"] = "decode"
+			resp["scanned"] = scanned
+			resp["truncated"] = truncated
+			resp["buckets"] = agg.Buckets()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// decodeSeries pages through the stored rows of typeName matching filter,
+// adding the value at field of each payload to agg. It stops after
+// maxSeriesScan rows, reporting truncation.
+func decodeSeries(db *sql.DB, reg *registry.Registry, typeName, field string,
+	filter storage.LogFilter, agg *storage.SeriesAggregator) (scanned int, truncated bool, err error) {
+	filter.Types = []string{typeName}
+	filter.Limit = 5000
+
+	for {
+		rows, err := storage.QueryLogsFiltered(db, filter)
+		if err != nil {
+			return scanned, false, err
+		}
+		for _, row := range rows {
+			// Payloads without a value at field, or that fail to
+			// decode, are skipped.
+			if v, err := reg.Number(typeName, row.Payload, row.EventTSMs, field); err == nil {
+				agg.Add(row.EventTSMs, v)
+			}
+		}
+		scanned += len(rows)
+		if len(rows) < filter.Limit {
+			return scanned, false, nil
+		}
+		if scanned >= maxSeriesScan {
+			return scanned, true, nil
+		}
+		last := rows[len(rows)-1]
+		filter.CursorTS, filter.CursorID = last.EventTSMs, last.ID
+	}
+}
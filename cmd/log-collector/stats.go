@@ -0,0 +1,207 @@
+/*******************************************************************************
+*  cmd/log-collector/stats.go
+*
+*  Aggregates over stored logs, computed in SQL with the filters of /api/logs:
+*
+*      /api/stats/histogram?start=..&end=..&bucket=1m&group=service&level=ERROR
+*      /api/stats/groupby?start=..&end=..&by=service&by=level
+*
+*  The histogram counts rows per time bucket (and group); groupby counts rows
+*  per combination of values. /api/facets lists the distinct values to
+*  populate filters with, including data ingested before a restart.
+*******************************************************************************/
+
+package main
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/Espeer5/protolog/internal/auth"
+	"github.com/Espeer5/protolog/internal/storage"
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// maxGroups bounds the groups returned by /api/stats/groupby and the values
+// per column returned by /api/facets.
+const maxGroups = 1000
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// levelName turns a level grouped as its number back into its name.
+func levelName(s string) string {
+	if n, err := strconv.Atoi(s); err == nil {
+		return logging.LogLevel(n).String()
+	}
+	return s
+}
+
+// histogramHandler serves GET /api/stats/histogram?start=..&end=..
+// [&bucket=1m][&group=COLUMN] plus the filters of /api/logs.
+func histogramHandler(db *sql.DB, a *auth.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		filter, err := parseLogFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.EndMs <= filter.StartMs {
+			http.Error(w, "end must be after start", http.StatusBadRequest)
+			return
+		}
+		bucketMs, err := parseBucket(q.Get("bucket"), filter.EndMs-filter.StartMs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if (filter.EndMs-filter.StartMs)/bucketMs >= maxSeriesBuckets {
+			http.Error(w, fmt.Sprintf("more than %d buckets; use a wider bucket", maxSeriesBuckets),
+				http.StatusBadRequest)
+			return
+		}
+		group := q.Get("group")
+		if group != "" && !storage.IsGroupColumn(group) {
+			http.Error(w, fmt.Sprintf("cannot group by %q", group), http.StatusBadRequest)
+			return
+		}
+		filter.Grants = storageGrants(viewFor(a, r))
+
+		buckets, err := storage.Histogram(db, filter, bucketMs, group)
+		if err != nil {
+			http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if buckets == nil {
+			buckets = []storage.HistogramBucket{}
+		}
+		if group == "level" {
+			for i := range buckets {
+				buckets[i].Group = levelName(buckets[i].Group)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"bucket_ms": bucketMs,
+			"group":     group,
+			"buckets":   buckets,
+		})
+	}
+}
+
+// groupByHandler serves GET /api/stats/groupby?start=..&end=..&by=COLUMN
+// [&by=COLUMN...][&limit=N] plus the filters of /api/logs.
+func groupByHandler(db *sql.DB, a *auth.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		filter, err := parseLogFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		by := q["by"]
+		if len(by) == 0 {
+			http.Error(w, "missing by", http.StatusBadRequest)
+			return
+		}
+		for _, col := range by {
+			if !storage.IsGroupColumn(col) {
+				http.Error(w, fmt.Sprintf("cannot group by %q", col), http.StatusBadRequest)
+				return
+			}
+		}
+		filter.Limit = maxGroups
+		if s := q.Get("limit"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil && v > 0 && v < maxGroups {
+				filter.Limit = v
+			}
+		}
+		filter.Grants = storageGrants(viewFor(a, r))
+
+		groups, err := storage.GroupBy(db, filter, by)
+		if err != nil {
+			http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if groups == nil {
+			groups = []storage.GroupCount{}
+		}
+		for _, g := range groups {
+			if l, ok := g.Groups["level"]; ok {
+				g.Groups["level"] = levelName(l)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"by":     by,
+			"groups": groups,
+		})
+	}
+}
+
+// facetsHandler serves GET /api/facets?start=..&end=..[&limit=N] plus the
+// filters of /api/logs: the distinct topics, services, hosts and types
+// stored in the range and the number of rows per level.
+func facetsHandler(db *sql.DB, a *auth.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		filter, err := parseLogFilter(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = maxGroups
+		if s := q.Get("limit"); s != "" {
+			if v, err := strconv.Atoi(s); err == nil && v > 0 && v < maxGroups {
+				filter.Limit = v
+			}
+		}
+		filter.Grants = storageGrants(viewFor(a, r))
+
+		facets, err := storage.Facets(db, filter, []string{"topic", "service", "host", "type", "level"})
+		if err != nil {
+			http.Error(w, "query failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		values := func(col string) []string {
+			out := make([]string, 0, len(facets[col]))
+			for _, v := range facets[col] {
+				out = append(out, v.Value)
+			}
+			sort.Strings(out)
+			return out
+		}
+		levels := make(map[string]int64, len(facets["level"]))
+		for _, v := range facets["level"] {
+			levels[levelName(v.Value)] = v.Count
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"topics":   values("topic"),
+			"services": values("service"),
+			"hosts":    values("host"),
+			"types":    values("type"),
+			"levels":   levels,
+		})
+	}
+}
@@ -5,9 +5,16 @@ go 1.24.0
 toolchain go1.24.5
 
 require (
+	github.com/bufbuild/protocompile v0.14.1
+	github.com/coreos/go-oidc/v3 v3.11.0
+	github.com/fsnotify/fsnotify v1.9.0
+	github.com/go-jose/go-jose/v4 v4.0.2
 	github.com/gorilla/websocket v1.5.3
 	github.com/pebbe/zmq4 v1.4.0
+	go.opentelemetry.io/proto/otlp v1.7.1
+	golang.org/x/crypto v0.31.0
 	google.golang.org/protobuf v1.36.10
+	modernc.org/sqlite v1.42.2
 )
 
 require (
@@ -17,9 +24,10 @@ require (
 	github.com/ncruces/go-strftime v0.1.9 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/oauth2 v0.21.0 // indirect
+	golang.org/x/sync v0.16.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	modernc.org/libc v1.66.10 // indirect
 	modernc.org/mathutil v1.7.1 // indirect
 	modernc.org/memory v1.11.0 // indirect
-	modernc.org/sqlite v1.42.2 // indirect
 )
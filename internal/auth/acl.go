@@ -0,0 +1,142 @@
+/*******************************************************************************
+*  internal/auth/acl.go
+*
+*  Per-topic access control. Roles grant read access to topic and service
+*  patterns; principals carry roles, and everything they read through the API
+*  or the live stream is limited to what their roles grant. Topics outside the
+*  grants are not refused but simply invisible.
+*******************************************************************************/
+
+package auth
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Grant allows reading logs whose topic matches one of Topics and whose
+// service matches one of Services. Patterns may use '*' (any run of
+// characters) and '?' (any single character); an empty list matches anything.
+type Grant struct {
+	Topics   []string `json:"topics,omitempty"`
+	Services []string `json:"services,omitempty"`
+}
+
+// View is the set of logs visible to one principal. A nil *View sees every
+// log; a View without grants sees none.
+type View struct {
+	grants []Grant
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+func validateGrants(role string, grants []Grant) error {
+	for _, g := range grants {
+		for _, p := range append(append([]string(nil), g.Topics...), g.Services...) {
+			if p == "" || strings.ContainsAny(p, "[]") {
+				return fmt.Errorf("role %q: invalid pattern %q (only '*' and '?' wildcards are supported)", role, p)
+			}
+		}
+	}
+	return nil
+}
+
+// ViewFor returns what p may read. Admins, and everyone when no roles are
+// configured, get an unrestricted (nil) view.
+func (a *Auth) ViewFor(p *Principal) *View {
+	if a == nil || len(a.roles) == 0 || p == nil || p.Scope == ScopeAdmin {
+		return nil
+	}
+
+	v := &View{grants: []Grant{}}
+	for _, r := range p.Roles {
+		v.grants = append(v.grants, a.roles[r]...)
+	}
+	return v
+}
+
+// Grants returns the grants making up v, or nil if v is unrestricted.
+func (v *View) Grants() []Grant {
+	if v == nil {
+		return nil
+	}
+	return v.grants
+}
+
+// Allows reports whether a log with the given topic and service is visible.
+func (v *View) Allows(topic, service string) bool {
+	if v == nil {
+		return true
+	}
+	for _, g := range v.grants {
+		if matchAny(g.Topics, topic) && matchAny(g.Services, service) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsTopic reports whether any log in topic may be visible, i.e. whether
+// the topic itself should be listed.
+func (v *View) AllowsTopic(topic string) bool {
+	if v == nil {
+		return true
+	}
+	for _, g := range v.grants {
+		if matchAny(g.Topics, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if matchGlob(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches s against a pattern of literal characters, '*' and '?',
+// with the same semantics as SQLite's GLOB for such patterns.
+func matchGlob(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	pi, si := 0, 0
+	star, mark := -1, 0
+
+	for si < len(str) {
+		switch {
+		case pi < len(p) && (p[pi] == '?' || p[pi] == str[si]):
+			pi++
+			si++
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, si
+			pi++
+		case star >= 0:
+			pi = star + 1
+			mark++
+			si = mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"audit.*", "audit.login", true},
+		{"audit.*", "audit", false},
+		{"*", "", true},
+		{"svc-?", "svc-a", true},
+		{"svc-?", "svc-ab", false},
+		{"*.errors", "billing.api.errors", true},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"exact", "exact", true},
+		{"exact", "Exact", false},
+	}
+	for _, tc := range cases {
+		if got := matchGlob(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestViewFor(t *testing.T) {
+	a, err := New(context.Background(), &Config{
+		Roles: map[string][]Grant{
+			"payments": {{Topics: []string{"payments.*"}}},
+			"api-ops":  {{Topics: []string{"http.*"}, Services: []string{"api-*"}}},
+		},
+		Tokens: []TokenConfig{
+			{Name: "pay", Token: "t1", Scope: ScopeRead, Roles: []string{"payments"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	if v := a.ViewFor(&Principal{Scope: ScopeAdmin}); v != nil {
+		t.Errorf("admin view = %+v, want unrestricted", v)
+	}
+
+	none := a.ViewFor(&Principal{Scope: ScopeRead})
+	if none == nil || none.Allows("payments.card", "") || none.AllowsTopic("http.access") {
+		t.Errorf("principal without roles should see nothing")
+	}
+
+	v := a.ViewFor(&Principal{Scope: ScopeRead, Roles: []string{"payments", "api-ops"}})
+	checks := []struct {
+		topic, service string
+		want           bool
+	}{
+		{"payments.card", "billing", true},
+		{"audit.login", "auth", false},
+		{"http.access", "api-gw", true},
+		{"http.access", "frontend", false},
+	}
+	for _, c := range checks {
+		if got := v.Allows(c.topic, c.service); got != c.want {
+			t.Errorf("Allows(%q, %q) = %v, want %v", c.topic, c.service, got, c.want)
+		}
+	}
+	if !v.AllowsTopic("http.access") || v.AllowsTopic("audit.login") {
+		t.Errorf("AllowsTopic mismatch")
+	}
+
+	var open *Auth
+	if v := open.ViewFor(nil); !v.Allows("audit.login", "") {
+		t.Errorf("nil Auth view should see everything")
+	}
+}
+
+func TestNew_UnknownRole(t *testing.T) {
+	_, err := New(context.Background(), &Config{
+		Tokens: []TokenConfig{{Name: "x", Token: "t", Scope: ScopeRead, Roles: []string{"nope"}}},
+	})
+	if err == nil {
+		t.Fatal("New succeeded with unknown role, want error")
+	}
+}
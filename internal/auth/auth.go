@@ -0,0 +1,323 @@
+/*******************************************************************************
+*  internal/auth/auth.go
+*
+*  Authentication for the collector's HTTP API and WebSocket. Requests are
+*  authenticated by static bearer tokens, HTTP basic credentials checked
+*  against bcrypt hashes, or OIDC-issued JWTs, and every principal carries a
+*  scope limiting it to read-only, ingest or admin operations.
+*******************************************************************************/
+
+package auth
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Scope is the set of operations a principal may perform.
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"   // query logs, topics, stats and the live stream
+	ScopeIngest Scope = "ingest" // push logs over HTTP (e.g. OTLP)
+	ScopeAdmin  Scope = "admin"  // everything, including configuration changes
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name   string
+	Scope  Scope
+	Roles  []string // ACL roles, see ViewFor
+	Method string   // "token", "basic", "oidc", or "none" when auth is disabled
+}
+
+// Config is the JSON document loaded by LoadConfig.
+type Config struct {
+	Tokens []TokenConfig `json:"tokens"`
+	Basic  []BasicConfig `json:"basic"`
+	OIDC   *OIDCConfig   `json:"oidc,omitempty"`
+
+	// Roles maps role names to the topics they may read. When any role is
+	// defined, non-admin principals only see logs granted by their roles.
+	Roles map[string][]Grant `json:"roles,omitempty"`
+}
+
+// TokenConfig is a static bearer token. Either Token or its hex-encoded
+// SHA-256 (TokenSHA256) may be given; the latter keeps secrets out of the
+// config file.
+type TokenConfig struct {
+	Name        string   `json:"name"`
+	Token       string   `json:"token,omitempty"`
+	TokenSHA256 string   `json:"token_sha256,omitempty"`
+	Scope       Scope    `json:"scope"`
+	Roles       []string `json:"roles,omitempty"`
+}
+
+// BasicConfig is an HTTP basic user with a bcrypt password hash.
+type BasicConfig struct {
+	User   string   `json:"user"`
+	Bcrypt string   `json:"bcrypt"`
+	Scope  Scope    `json:"scope"`
+	Roles  []string `json:"roles,omitempty"`
+}
+
+// Auth authenticates requests. A nil *Auth disables authentication and
+// treats every caller as an anonymous admin.
+type Auth struct {
+	tokens map[[sha256.Size]byte]TokenConfig
+	basic  map[string]BasicConfig
+	oidc   *oidcVerifier
+	roles  map[string][]Grant
+}
+
+type ctxKey struct{}
+
+/*******************************************************************************
+*  ERRORS
+*******************************************************************************/
+
+var (
+	// ErrNoCredentials is returned when a request carries no credentials.
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials is returned for unknown or malformed credentials.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// LoadConfig reads an auth configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read auth config: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse auth config %q: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// New builds an Auth from cfg. OIDC discovery, if configured, is performed
+// against the issuer using ctx.
+func New(ctx context.Context, cfg *Config) (*Auth, error) {
+	a := &Auth{
+		tokens: make(map[[sha256.Size]byte]TokenConfig),
+		basic:  make(map[string]BasicConfig),
+		roles:  cfg.Roles,
+	}
+
+	for role, grants := range cfg.Roles {
+		if err := validateGrants(role, grants); err != nil {
+			return nil, err
+		}
+	}
+	checkRoles := func(what string, roles []string) error {
+		for _, r := range roles {
+			if _, ok := cfg.Roles[r]; !ok {
+				return fmt.Errorf("%s: unknown role %q", what, r)
+			}
+		}
+		return nil
+	}
+
+	for i, t := range cfg.Tokens {
+		if err := t.Scope.validate(); err != nil {
+			return nil, fmt.Errorf("tokens[%d] (%s): %w", i, t.Name, err)
+		}
+		var sum [sha256.Size]byte
+		switch {
+		case t.Token != "" && t.TokenSHA256 != "":
+			return nil, fmt.Errorf("tokens[%d] (%s): set token or token_sha256, not both", i, t.Name)
+		case t.Token != "":
+			sum = sha256.Sum256([]byte(t.Token))
+		case t.TokenSHA256 != "":
+			b, err := hex.DecodeString(t.TokenSHA256)
+			if err != nil || len(b) != sha256.Size {
+				return nil, fmt.Errorf("tokens[%d] (%s): token_sha256 must be 64 hex characters", i, t.Name)
+			}
+			copy(sum[:], b)
+		default:
+			return nil, fmt.Errorf("tokens[%d] (%s): missing token", i, t.Name)
+		}
+		if err := checkRoles(fmt.Sprintf("tokens[%d] (%s)", i, t.Name), t.Roles); err != nil {
+			return nil, err
+		}
+		t.Token = ""
+		a.tokens[sum] = t
+	}
+
+	for i, b := range cfg.Basic {
+		if err := b.Scope.validate(); err != nil {
+			return nil, fmt.Errorf("basic[%d] (%s): %w", i, b.User, err)
+		}
+		if _, err := bcrypt.Cost([]byte(b.Bcrypt)); err != nil {
+			return nil, fmt.Errorf("basic[%d] (%s): invalid bcrypt hash: %w", i, b.User, err)
+		}
+		if err := checkRoles(fmt.Sprintf("basic[%d] (%s)", i, b.User), b.Roles); err != nil {
+			return nil, err
+		}
+		a.basic[b.User] = b
+	}
+
+	if cfg.OIDC != nil {
+		v, err := newOIDCVerifier(ctx, cfg.OIDC, cfg.Roles)
+		if err != nil {
+			return nil, err
+		}
+		a.oidc = v
+	}
+
+	return a, nil
+}
+
+func (s Scope) validate() error {
+	switch s {
+	case ScopeRead, ScopeIngest, ScopeAdmin:
+		return nil
+	}
+	return fmt.Errorf("unknown scope %q (want read, ingest or admin)", s)
+}
+
+// Allows reports whether a principal with scope s may perform an operation
+// requiring scope want. Admin implies every other scope.
+func (s Scope) Allows(want Scope) bool {
+	return s == ScopeAdmin || s == want
+}
+
+// Authenticate identifies the caller of r. Bearer tokens are taken from the
+// Authorization header or, for WebSocket upgrades only (browsers cannot set
+// headers there), from the access_token query parameter.
+func (a *Auth) Authenticate(r *http.Request) (*Principal, error) {
+	if a == nil {
+		return &Principal{Name: "anonymous", Scope: ScopeAdmin, Method: "none"}, nil
+	}
+
+	if user, pass, ok := r.BasicAuth(); ok {
+		b, found := a.basic[user]
+		if !found {
+			// Burn comparable time so unknown users are not distinguishable.
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(pass))
+			return nil, ErrInvalidCredentials
+		}
+		if bcrypt.CompareHashAndPassword([]byte(b.Bcrypt), []byte(pass)) != nil {
+			return nil, ErrInvalidCredentials
+		}
+		return &Principal{Name: user, Scope: b.Scope, Roles: b.Roles, Method: "basic"}, nil
+	}
+
+	token := bearerToken(r)
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	for k, t := range a.tokens {
+		if subtle.ConstantTimeCompare(k[:], sum[:]) == 1 {
+			return &Principal{Name: t.Name, Scope: t.Scope, Roles: t.Roles, Method: "token"}, nil
+		}
+	}
+
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		return a.oidc.verify(r.Context(), token)
+	}
+
+	return nil, ErrInvalidCredentials
+}
+
+// dummyHash is compared against when the basic-auth user is unknown.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("protolog"), bcrypt.MinCost)
+
+func bearerToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if scheme, tok, ok := strings.Cut(h, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(tok)
+		}
+		return ""
+	}
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return r.URL.Query().Get("access_token")
+	}
+	return ""
+}
+
+// Require wraps next so that it only runs for principals allowed scope. The
+// principal is available to next through FromContext.
+func (a *Auth) Require(scope Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		if err != nil {
+			if len(a.basic) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="protolog", charset="UTF-8"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="protolog"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !p.Scope.Allows(scope) {
+			http.Error(w, "forbidden: requires "+string(scope)+" scope", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, p)))
+	})
+}
+
+// RequireFunc is Require for handler functions.
+func (a *Auth) RequireFunc(scope Scope, next http.HandlerFunc) http.Handler {
+	return a.Require(scope, next)
+}
+
+// FromContext returns the principal stored by Require.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok
+}
+
+// OriginChecker returns a WebSocket CheckOrigin function. Requests without an
+// Origin header (non-browser clients) and same-host origins are accepted;
+// any other origin must appear in allowed (e.g. "https://logs.example.com").
+func OriginChecker(allowed []string) func(r *http.Request) bool {
+	set := make(map[string]struct{}, len(allowed))
+	for _, o := range allowed {
+		set[strings.TrimRight(strings.ToLower(o), "/")] = struct{}{}
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		_, ok := set[strings.ToLower(u.Scheme+"://"+u.Host)]
+		return ok
+	}
+}
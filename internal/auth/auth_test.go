@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAuth(t *testing.T) *Auth {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt failed: %v", err)
+	}
+	sum := sha256.Sum256([]byte("admin-token"))
+
+	a, err := New(context.Background(), &Config{
+		Tokens: []TokenConfig{
+			{Name: "dashboard", Token: "read-token", Scope: ScopeRead},
+			{Name: "ops", TokenSHA256: hex.EncodeToString(sum[:]), Scope: ScopeAdmin},
+		},
+		Basic: []BasicConfig{{User: "alice", Bcrypt: string(hash), Scope: ScopeIngest}},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return a
+}
+
+func TestRequire_Scopes(t *testing.T) {
+	a := newTestAuth(t)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := FromContext(r.Context())
+		w.Header().Set("X-Principal", p.Name)
+	})
+
+	cases := []struct {
+		name   string
+		scope  Scope
+		setup  func(r *http.Request)
+		status int
+		who    string
+	}{
+		{"no credentials", ScopeRead, func(r *http.Request) {}, http.StatusUnauthorized, ""},
+		{"bad token", ScopeRead, func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized, ""},
+		{"read token reads", ScopeRead, func(r *http.Request) { r.Header.Set("Authorization", "Bearer read-token") }, http.StatusOK, "dashboard"},
+		{"read token cannot admin", ScopeAdmin, func(r *http.Request) { r.Header.Set("Authorization", "Bearer read-token") }, http.StatusForbidden, ""},
+		{"hashed admin token", ScopeRead, func(r *http.Request) { r.Header.Set("Authorization", "Bearer admin-token") }, http.StatusOK, "ops"},
+		{"basic ingest", ScopeIngest, func(r *http.Request) { r.SetBasicAuth("alice", "s3cret") }, http.StatusOK, "alice"},
+		{"basic wrong password", ScopeIngest, func(r *http.Request) { r.SetBasicAuth("alice", "x") }, http.StatusUnauthorized, ""},
+		{"basic unknown user", ScopeIngest, func(r *http.Request) { r.SetBasicAuth("bob", "s3cret") }, http.StatusUnauthorized, ""},
+		{"query token ignored without upgrade", ScopeRead, func(r *http.Request) { r.URL.RawQuery = "access_token=read-token" }, http.StatusUnauthorized, ""},
+		{"query token on websocket upgrade", ScopeRead, func(r *http.Request) {
+			r.URL.RawQuery = "access_token=read-token"
+			r.Header.Set("Upgrade", "websocket")
+		}, http.StatusOK, "dashboard"},
+	}
+
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/api/logs", nil)
+		tc.setup(r)
+		w := httptest.NewRecorder()
+		a.Require(tc.scope, ok).ServeHTTP(w, r)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, w.Code, tc.status)
+		}
+		if got := w.Header().Get("X-Principal"); got != tc.who {
+			t.Errorf("%s: principal = %q, want %q", tc.name, got, tc.who)
+		}
+	}
+}
+
+func TestRequire_NilAuthAllowsAll(t *testing.T) {
+	var a *Auth
+	called := false
+	h := a.Require(ScopeAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Fatal("nil Auth rejected request")
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	bad := []*Config{
+		{Tokens: []TokenConfig{{Name: "x", Token: "t", Scope: "root"}}},
+		{Tokens: []TokenConfig{{Name: "x", Scope: ScopeRead}}},
+		{Tokens: []TokenConfig{{Name: "x", TokenSHA256: "abc", Scope: ScopeRead}}},
+		{Basic: []BasicConfig{{User: "u", Bcrypt: "plaintext", Scope: ScopeRead}}},
+	}
+	for i, cfg := range bad {
+		if _, err := New(context.Background(), cfg); err == nil {
+			t.Errorf("config %d: New succeeded, want error", i)
+		}
+	}
+}
+
+func TestOriginChecker(t *testing.T) {
+	check := OriginChecker([]string{"https://logs.example.com/"})
+
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://collector:8080", true},
+		{"https://logs.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://logs.example.com", false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://collector:8080/ws/logs", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if got := check(r); got != tc.want {
+			t.Errorf("origin %q: got %v, want %v", tc.origin, got, tc.want)
+		}
+	}
+}
+
+// testIdP is a minimal OIDC provider serving discovery and JWKS documents.
+type testIdP struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey failed: %v", err)
+	}
+	idp := &testIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.srv.URL,
+			"jwks_uri":                              idp.srv.URL + "/keys",
+			"authorization_endpoint":                idp.srv.URL + "/auth",
+			"token_endpoint":                        idp.srv.URL + "/token",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "k1", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *testIdP) token(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: idp.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "k1"))
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	payload, _ := json.Marshal(claims)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	raw, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("CompactSerialize failed: %v", err)
+	}
+	return raw
+}
+
+func TestAuthenticate_OIDC(t *testing.T) {
+	idp := newTestIdP(t)
+
+	a, err := New(context.Background(), &Config{OIDC: &OIDCConfig{
+		Issuer:      idp.srv.URL,
+		ClientID:    "protolog",
+		ScopeClaim:  "groups",
+		AdminValues: []string{"sre"},
+		ReadValues:  []string{"dev"},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	now := time.Now()
+	base := func(groups ...string) map[string]any {
+		return map[string]any{
+			"iss": idp.srv.URL, "aud": "protolog", "sub": "u1",
+			"preferred_username": "carol",
+			"iat":                now.Unix(), "exp": now.Add(time.Hour).Unix(),
+			"groups": groups,
+		}
+	}
+
+	cases := []struct {
+		name   string
+		claims map[string]any
+		scope  Scope
+		ok     bool
+	}{
+		{"admin group", base("dev", "sre"), ScopeAdmin, true},
+		{"read group", base("dev"), ScopeRead, true},
+		{"no matching group", base("sales"), "", false},
+		{"wrong audience", func() map[string]any { c := base("sre"); c["aud"] = "other"; return c }(), "", false},
+		{"expired", func() map[string]any { c := base("sre"); c["exp"] = now.Add(-time.Hour).Unix(); return c }(), "", false},
+	}
+
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/api/logs", nil)
+		r.Header.Set("Authorization", "Bearer "+idp.token(t, tc.claims))
+		p, err := a.Authenticate(r)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("%s: Authenticate succeeded, want error", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Authenticate failed: %v", tc.name, err)
+			continue
+		}
+		if p.Name != "carol" || p.Scope != tc.scope || p.Method != "oidc" {
+			t.Errorf("%s: principal = %+v", tc.name, p)
+		}
+	}
+}
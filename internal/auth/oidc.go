@@ -0,0 +1,147 @@
+/*******************************************************************************
+*  internal/auth/oidc.go
+*
+*  Optional OIDC bearer-token verification. ID tokens issued by the configured
+*  provider are checked against its published keys, and a claim in the token
+*  decides the caller's scope.
+*******************************************************************************/
+
+package auth
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// OIDCConfig configures verification of tokens from an OIDC provider.
+//
+// The scope of a verified caller is taken from ScopeClaim (a string or list
+// of strings, e.g. "groups"): a value listed in AdminValues grants admin,
+// IngestValues grants ingest, ReadValues grants read. Tokens matching none of
+// them get DefaultScope, or are rejected if it is empty. Values of RoleClaim
+// that name a configured role are assigned to the caller as ACL roles.
+type OIDCConfig struct {
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ScopeClaim   string   `json:"scope_claim,omitempty"`
+	AdminValues  []string `json:"admin_values,omitempty"`
+	IngestValues []string `json:"ingest_values,omitempty"`
+	ReadValues   []string `json:"read_values,omitempty"`
+	DefaultScope Scope    `json:"default_scope,omitempty"`
+	RoleClaim    string   `json:"role_claim,omitempty"`
+}
+
+type oidcVerifier struct {
+	cfg      *OIDCConfig
+	roles    map[string][]Grant
+	verifier *oidc.IDTokenVerifier
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+func newOIDCVerifier(ctx context.Context, cfg *OIDCConfig, roles map[string][]Grant) (*oidcVerifier, error) {
+	if cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("oidc: issuer and client_id are required")
+	}
+	if cfg.DefaultScope != "" {
+		if err := cfg.DefaultScope.validate(); err != nil {
+			return nil, fmt.Errorf("oidc default_scope: %w", err)
+		}
+	}
+
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery for %q: %w", cfg.Issuer, err)
+	}
+
+	return &oidcVerifier{
+		cfg:      cfg,
+		roles:    roles,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+func (v *oidcVerifier) verify(ctx context.Context, raw string) (*Principal, error) {
+	tok, err := v.verifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	var claims map[string]any
+	if err := tok.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	name := tok.Subject
+	if s, ok := claims["preferred_username"].(string); ok && s != "" {
+		name = s
+	} else if s, ok := claims["email"].(string); ok && s != "" {
+		name = s
+	}
+
+	scope := v.scopeFor(claimValues(claims[v.cfg.ScopeClaim]))
+	if scope == "" {
+		return nil, fmt.Errorf("%w: token for %q grants no scope", ErrInvalidCredentials, name)
+	}
+	var roles []string
+	if v.cfg.RoleClaim != "" {
+		for _, r := range claimValues(claims[v.cfg.RoleClaim]) {
+			if _, ok := v.roles[r]; ok {
+				roles = append(roles, r)
+			}
+		}
+	}
+	return &Principal{Name: name, Scope: scope, Roles: roles, Method: "oidc"}, nil
+}
+
+// scopeFor picks the broadest scope granted by any of the claim values.
+func (v *oidcVerifier) scopeFor(values []string) Scope {
+	has := func(list []string) bool {
+		for _, want := range list {
+			for _, got := range values {
+				if got == want {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has(v.cfg.AdminValues):
+		return ScopeAdmin
+	case has(v.cfg.IngestValues):
+		return ScopeIngest
+	case has(v.cfg.ReadValues):
+		return ScopeRead
+	}
+	return v.cfg.DefaultScope
+}
+
+func claimValues(c any) []string {
+	switch c := c.(type) {
+	case string:
+		return []string{c}
+	case []any:
+		out := make([]string, 0, len(c))
+		for _, e := range c {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -8,9 +8,11 @@ package config
 
 // Config holds runtime configuration for the collector.
 type Config struct {
-	DataDir        string   // where to store logs
-	BufferSize     int      // number of recent messages to keep per topic
-	DescriptorSets []string // list of .desc files to load into the registry
+	DataDir        string     // where to store logs
+	BufferSize     int        // number of recent messages to keep per topic
+	DescriptorSets []string   // list of .desc files to load into the registry
+	Endpoints      []Endpoint // ZMQ SUB endpoints to bind or connect
+	Subscriptions  []string   // topic prefixes to subscribe to; empty means all
 }
 
 // DefaultBufferSize is a sane default if not overridden.
@@ -23,4 +25,3 @@ func DefaultConfig() *Config {
 		DescriptorSets: []string{"/home/ec2-user/sandbox/nimbus/protolog/schema.desc", "/home/ec2-user/sandbox/nimbus/ascend_core/ascend_core.desc", "/home/ec2-user/sandbox/nimbus/nimbus.desc"},
 	}
 }
-
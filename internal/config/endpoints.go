@@ -0,0 +1,103 @@
+/*******************************************************************************
+*  internal/config/endpoints.go
+*
+*  ZMQ ingest endpoint configuration. The collector may bind or connect any
+*  number of SUB sockets, e.g. an IPC endpoint for local processes, a TCP
+*  endpoint for the LAN, and connections to publishers that bind themselves.
+*******************************************************************************/
+
+package config
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// EndpointMode selects whether a SUB socket binds or connects its address.
+type EndpointMode string
+
+const (
+	ModeBind    EndpointMode = "bind"
+	ModeConnect EndpointMode = "connect"
+)
+
+// Endpoint describes a single ZMQ SUB endpoint.
+type Endpoint struct {
+	Addr   string       // ZMQ address, e.g. tcp://*:5556 or ipc:///tmp/protolog.ipc
+	Mode   EndpointMode // bind (default) or connect
+	RcvHWM int          // receive high-water mark; 0 keeps the ZMQ default
+	Curve  bool         // act as CURVE server (requires a collector secret key)
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// ParseEndpoint parses an endpoint spec of the form
+//
+//	[bind:|connect:]ADDR[,hwm=N][,curve]
+//
+// e.g. "tcp://*:5556", "connect:tcp://10.0.0.7:5557,hwm=5000,curve".
+func ParseEndpoint(spec string) (Endpoint, error) {
+	ep := Endpoint{Mode: ModeBind}
+
+	spec = strings.TrimSpace(spec)
+	addr, opts, _ := strings.Cut(spec, ",")
+
+	if mode, rest, ok := strings.Cut(addr, ":"); ok {
+		switch EndpointMode(mode) {
+		case ModeBind, ModeConnect:
+			ep.Mode = EndpointMode(mode)
+			addr = rest
+		}
+	}
+	if !strings.Contains(addr, "://") {
+		return Endpoint{}, fmt.Errorf("endpoint %q: missing transport (e.g. tcp://, ipc://)", spec)
+	}
+	ep.Addr = addr
+
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "" {
+			continue
+		}
+		key, val, _ := strings.Cut(opt, "=")
+		switch key {
+		case "hwm":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 0 {
+				return Endpoint{}, fmt.Errorf("endpoint %q: invalid hwm %q", spec, val)
+			}
+			ep.RcvHWM = n
+		case "curve":
+			if val != "" {
+				return Endpoint{}, fmt.Errorf("endpoint %q: curve takes no value", spec)
+			}
+			ep.Curve = true
+		default:
+			return Endpoint{}, fmt.Errorf("endpoint %q: unknown option %q", spec, key)
+		}
+	}
+
+	return ep, nil
+}
+
+// String renders the endpoint back into the form accepted by ParseEndpoint.
+func (e Endpoint) String() string {
+	s := string(e.Mode) + ":" + e.Addr
+	if e.RcvHWM > 0 {
+		s += ",hwm=" + strconv.Itoa(e.RcvHWM)
+	}
+	if e.Curve {
+		s += ",curve"
+	}
+	return s
+}
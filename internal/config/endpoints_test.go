@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestParseEndpoint(t *testing.T) {
+	cases := []struct {
+		spec string
+		want Endpoint
+	}{
+		{"tcp://*:5556", Endpoint{Addr: "tcp://*:5556", Mode: ModeBind}},
+		{"bind:ipc:///tmp/protolog.ipc", Endpoint{Addr: "ipc:///tmp/protolog.ipc", Mode: ModeBind}},
+		{"connect:tcp://10.0.0.7:5557,hwm=5000", Endpoint{Addr: "tcp://10.0.0.7:5557", Mode: ModeConnect, RcvHWM: 5000}},
+		{"tcp://*:5556,curve", Endpoint{Addr: "tcp://*:5556", Mode: ModeBind, Curve: true}},
+	}
+
+	for _, tc := range cases {
+		got, err := ParseEndpoint(tc.spec)
+		if err != nil {
+			t.Errorf("ParseEndpoint(%q) returned error: %v", tc.spec, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseEndpoint(%q) = %+v, want %+v", tc.spec, got, tc.want)
+		}
+		if again, err := ParseEndpoint(got.String()); err != nil || again != got {
+			t.Errorf("round trip of %q = %+v, %v", got.String(), again, err)
+		}
+	}
+}
+
+func TestParseEndpoint_Invalid(t *testing.T) {
+	for _, spec := range []string{"", "connect:localhost:5556", "tcp://*:5556,hwm=x", "tcp://*:5556,foo=1", "tcp://*:5556,curve=1"} {
+		if _, err := ParseEndpoint(spec); err == nil {
+			t.Errorf("ParseEndpoint(%q) succeeded, want error", spec)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*******************************************************************************
+*  internal/ingest/curve.go
+*
+*  ZMQ CURVE authentication and encryption for ingest endpoints that cross
+*  untrusted networks. The collector acts as CURVE server; a ZAP handler only
+*  admits clients whose public key is on an allow-list, and tags every message
+*  they send with the client's identity.
+*******************************************************************************/
+
+package ingest
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pebbe/zmq4"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// ZAPDomain is the ZAP domain used by the collector's CURVE sockets.
+const ZAPDomain = "protolog"
+
+// curveKeyLen is the length of a Z85-encoded CURVE key.
+const curveKeyLen = 40
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// LoadCurveKey reads a single Z85-encoded key from path. Blank lines and
+// comment lines ("# ...") are ignored; '#' alone does not start a comment
+// since it is a valid Z85 character.
+func LoadCurveKey(path string) (string, error) {
+	keys, err := readKeyFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(keys) != 1 {
+		return "", fmt.Errorf("key file %q: want exactly one key, found %d", path, len(keys))
+	}
+	return keys[0][0], nil
+}
+
+// LoadCurveAllowList reads client public keys from path, one per line,
+// optionally followed by a name for the client:
+//
+//	# key                                    name
+//	rq:rM>}U?@Lns47E1%kR.o@n%FcmmsL/@{H8]yf7  sensor-gw-01
+//
+// The result maps each public key to its name (the key itself if unnamed).
+func LoadCurveAllowList(path string) (map[string]string, error) {
+	keys, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]string, len(keys))
+	for _, k := range keys {
+		name := k[0]
+		if len(k) > 1 {
+			name = k[1]
+		}
+		allowed[k[0]] = name
+	}
+	return allowed, nil
+}
+
+func readKeyFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open key file: %w", err)
+	}
+	defer f.Close()
+
+	var out [][]string
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line == "#" || strings.HasPrefix(line, "# ") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields[0]) != curveKeyLen {
+			return nil, fmt.Errorf("key file %q line %d: key must be %d Z85 characters",
+				path, n, curveKeyLen)
+		}
+		out = append(out, fields)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read key file %q: %w", path, err)
+	}
+	return out, nil
+}
+
+// StartCurveAuth starts the ZAP handler for ZAPDomain. Only the given client
+// public keys are admitted; a nil map admits any client (encryption without
+// authentication). The client's name is attached to each message as the
+// ZMQ "User-Id" property.
+func StartCurveAuth(allowed map[string]string) error {
+	if err := zmq4.AuthStart(); err != nil {
+		return fmt.Errorf("start ZAP handler: %w", err)
+	}
+
+	if allowed == nil {
+		zmq4.AuthCurveAdd(ZAPDomain, zmq4.CURVE_ALLOW_ANY)
+	} else {
+		keys := make([]string, 0, len(allowed))
+		for k := range allowed {
+			keys = append(keys, k)
+		}
+		zmq4.AuthCurveAdd(ZAPDomain, keys...)
+	}
+
+	zmq4.AuthSetMetadataHandler(func(version, requestID, domain, address, identity,
+		mechanism string, credentials ...string) map[string]string {
+		if mechanism != "CURVE" || len(credentials) == 0 {
+			return map[string]string{}
+		}
+		key := zmq4.Z85encode(credentials[0])
+		if name, ok := allowed[key]; ok {
+			return map[string]string{"User-Id": name}
+		}
+		return map[string]string{"User-Id": key}
+	})
+
+	return nil
+}
+
+// StopCurveAuth stops the ZAP handler started by StartCurveAuth.
+func StopCurveAuth() {
+	zmq4.AuthStop()
+}
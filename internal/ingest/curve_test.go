@@ -0,0 +1,62 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testKeyA = "rq:rM>}U?@Lns47E1%kR.o@n%FcmmsL/@{H8]yf7"
+	testKeyB = "#HHh/ZT0v-xw0FUZ<%pOs?q8>B(6Q3hC<L{p1=g."
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile(%q) failed: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadCurveKey(t *testing.T) {
+	path := writeFile(t, "server.key", "# protolog CURVE secret key\n"+testKeyA+"\n")
+
+	key, err := LoadCurveKey(path)
+	if err != nil {
+		t.Fatalf("LoadCurveKey failed: %v", err)
+	}
+	if key != testKeyA {
+		t.Errorf("LoadCurveKey = %q, want %q", key, testKeyA)
+	}
+
+	two := writeFile(t, "two.key", testKeyA+"\n"+testKeyA+"\n")
+	if _, err := LoadCurveKey(two); err == nil {
+		t.Errorf("LoadCurveKey with two keys succeeded, want error")
+	}
+}
+
+func TestLoadCurveAllowList(t *testing.T) {
+	// testKeyB begins with '#', which must not be mistaken for a comment.
+	path := writeFile(t, "allow", "# key  name\n\n"+testKeyA+"  sensor-gw-01\n"+testKeyB+"\n")
+
+	allowed, err := LoadCurveAllowList(path)
+	if err != nil {
+		t.Fatalf("LoadCurveAllowList failed: %v", err)
+	}
+	if len(allowed) != 2 {
+		t.Fatalf("got %d keys, want 2: %v", len(allowed), allowed)
+	}
+	if allowed[testKeyA] != "sensor-gw-01" {
+		t.Errorf("name for key A = %q, want %q", allowed[testKeyA], "sensor-gw-01")
+	}
+	if allowed[testKeyB] != testKeyB {
+		t.Errorf("unnamed key B should map to itself, got %q", allowed[testKeyB])
+	}
+
+	bad := writeFile(t, "bad", "tooshort name\n")
+	if _, err := LoadCurveAllowList(bad); err == nil {
+		t.Errorf("LoadCurveAllowList with short key succeeded, want error")
+	}
+}
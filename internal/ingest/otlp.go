@@ -0,0 +1,319 @@
+/*******************************************************************************
+*  internal/ingest/otlp.go
+*
+*  Bridge from OpenTelemetry log exports (OTLP/HTTP, protobuf encoding) into
+*  protolog LogEnvelopes, so that services instrumented with OpenTelemetry
+*  show up alongside native protolog topics.
+*******************************************************************************/
+
+package ingest
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+const (
+	// DefaultOTLPTopic is the topic given to OTLP records that do not carry a
+	// "protolog.topic" attribute.
+	DefaultOTLPTopic = "otlp"
+
+	// StructPayloadType is the envelope type of the structured payloads
+	// built by the ingest bridges.
+	StructPayloadType = "google.protobuf.Struct"
+
+	// OTLPTopicAttribute lets a producer pick its protolog topic from either
+	// the resource or the individual log record.
+	OTLPTopicAttribute = "protolog.topic"
+
+	// maxOTLPBody bounds the size of a single export request.
+	maxOTLPBody = 16 << 20
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// OTLPHandler returns an http.Handler implementing the OTLP/HTTP logs
+// endpoint (conventionally mounted at /v1/logs). Every decoded record is
+// passed to handle. Only the binary protobuf encoding is accepted.
+func OTLPHandler(handle func(*logging.LogEnvelope)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ct := r.Header.Get("Content-Type")
+		if ct != "" && !strings.HasPrefix(ct, "application/x-protobuf") {
+			http.Error(w, "unsupported content type (want application/x-protobuf)",
+				http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var body io.Reader = http.MaxBytesReader(w, r.Body, maxOTLPBody)
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, "invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			// One byte over the limit tells an oversized body from one
+			// that fits exactly.
+			body = io.LimitReader(gz, maxOTLPBody+1)
+		}
+
+		data, err := io.ReadAll(body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || len(data) > maxOTLPBody {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, "read body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// ExportLogsServiceRequest and LogsData share the same wire format,
+		// which spares us the gRPC service packages.
+		var req logsv1.LogsData
+		if err := proto.Unmarshal(data, &req); err != nil {
+			http.Error(w, "invalid OTLP logs request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for _, env := range OTLPToEnvelopes(&req) {
+			handle(env)
+		}
+
+		// An empty ExportLogsServiceResponse signals full success.
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// OTLPToEnvelopes maps every log record in an OTLP export into a LogEnvelope.
+//
+//   - resource host.name / service.name / process.pid -> host / service / pid
+//   - severity_number (or severity_text)               -> level
+//   - trace_id                                         -> correlation_id
+//   - body                                             -> summary
+//   - attributes, resource, scope, span                -> google.protobuf.Struct payload
+func OTLPToEnvelopes(req *logsv1.LogsData) []*logging.LogEnvelope {
+	var out []*logging.LogEnvelope
+
+	for _, rl := range req.GetResourceLogs() {
+		resAttrs := rl.GetResource().GetAttributes()
+		host := attrString(resAttrs, "host.name")
+		service := attrString(resAttrs, "service.name")
+		pid, _ := strconv.ParseInt(attrString(resAttrs, "process.pid"), 10, 32)
+		resTopic := attrString(resAttrs, OTLPTopicAttribute)
+
+		for _, sl := range rl.GetScopeLogs() {
+			scope := sl.GetScope()
+
+			for _, rec := range sl.GetLogRecords() {
+				topic := attrString(rec.GetAttributes(), OTLPTopicAttribute)
+				if topic == "" {
+					topic = resTopic
+				}
+				if topic == "" {
+					topic = DefaultOTLPTopic
+				}
+
+				env := &logging.LogEnvelope{
+					Topic:     topic,
+					Timestamp: otlpTimestamp(rec),
+					Level:     otlpLevel(rec.GetSeverityNumber(), rec.GetSeverityText()),
+					Host:      host,
+					Service:   service,
+					Pid:       int32(pid),
+				}
+
+				if tid := rec.GetTraceId(); len(tid) > 0 {
+					env.CorrelationId = hex.EncodeToString(tid)
+				}
+
+				fields := map[string]any{}
+				if body := rec.GetBody(); body != nil {
+					if s, ok := body.GetValue().(*commonv1.AnyValue_StringValue); ok {
+						env.Summary = s.StringValue
+					} else {
+						fields["body"] = anyValueToGo(body)
+					}
+				}
+				if env.Summary == "" {
+					env.Summary = rec.GetEventName()
+				}
+
+				if attrs := rec.GetAttributes(); len(attrs) > 0 {
+					fields["attributes"] = kvListToGo(attrs)
+				}
+				if len(resAttrs) > 0 {
+					fields["resource"] = kvListToGo(resAttrs)
+				}
+				if scope.GetName() != "" {
+					fields["scope"] = map[string]any{
+						"name":    scope.GetName(),
+						"version": scope.GetVersion(),
+					}
+				}
+				if sid := rec.GetSpanId(); len(sid) > 0 {
+					fields["span_id"] = hex.EncodeToString(sid)
+				}
+				if st := rec.GetSeverityText(); st != "" {
+					fields["severity_text"] = st
+				}
+				if name := rec.GetEventName(); name != "" {
+					fields["event_name"] = name
+				}
+
+				if len(fields) > 0 {
+					if payload, err := marshalStruct(fields); err == nil {
+						env.Type = StructPayloadType
+						env.Payload = payload
+					} else {
+						log.Printf("otlp: build payload: %v", err)
+					}
+				}
+
+				out = append(out, env)
+			}
+		}
+	}
+
+	return out
+}
+
+func otlpTimestamp(rec *logsv1.LogRecord) *timestamppb.Timestamp {
+	ns := rec.GetTimeUnixNano()
+	if ns == 0 {
+		ns = rec.GetObservedTimeUnixNano()
+	}
+	if ns == 0 {
+		return timestamppb.Now()
+	}
+	return timestamppb.New(time.Unix(0, int64(ns)))
+}
+
+// otlpLevel folds the 24 OTLP severity numbers into protolog's levels,
+// falling back to the severity text when no number was set.
+func otlpLevel(n logsv1.SeverityNumber, text string) logging.LogLevel {
+	switch {
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return logging.LogLevel_LOG_LEVEL_FATAL
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return logging.LogLevel_LOG_LEVEL_ERROR
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return logging.LogLevel_LOG_LEVEL_WARN
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return logging.LogLevel_LOG_LEVEL_INFO
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return logging.LogLevel_LOG_LEVEL_DEBUG
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(text)) {
+	case "TRACE", "DEBUG":
+		return logging.LogLevel_LOG_LEVEL_DEBUG
+	case "INFO", "NOTICE":
+		return logging.LogLevel_LOG_LEVEL_INFO
+	case "WARN", "WARNING":
+		return logging.LogLevel_LOG_LEVEL_WARN
+	case "ERROR", "ERR":
+		return logging.LogLevel_LOG_LEVEL_ERROR
+	case "FATAL", "CRITICAL", "CRIT", "PANIC":
+		return logging.LogLevel_LOG_LEVEL_FATAL
+	}
+	return logging.LogLevel_LOG_LEVEL_UNSPECIFIED
+}
+
+// attrString returns the attribute with the given key rendered as a string,
+// or "" if it is absent.
+func attrString(attrs []*commonv1.KeyValue, key string) string {
+	for _, kv := range attrs {
+		if kv.GetKey() != key {
+			continue
+		}
+		switch v := anyValueToGo(kv.GetValue()).(type) {
+		case nil:
+			return ""
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func kvListToGo(kvs []*commonv1.KeyValue) map[string]any {
+	out := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		out[kv.GetKey()] = anyValueToGo(kv.GetValue())
+	}
+	return out
+}
+
+// anyValueToGo converts an OTLP AnyValue into the plain Go values accepted
+// by structpb. Integers become float64, bytes become base64 strings.
+func anyValueToGo(v *commonv1.AnyValue) any {
+	switch x := v.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return x.StringValue
+	case *commonv1.AnyValue_BoolValue:
+		return x.BoolValue
+	case *commonv1.AnyValue_IntValue:
+		return float64(x.IntValue)
+	case *commonv1.AnyValue_DoubleValue:
+		return x.DoubleValue
+	case *commonv1.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(x.BytesValue)
+	case *commonv1.AnyValue_ArrayValue:
+		vals := x.ArrayValue.GetValues()
+		out := make([]any, 0, len(vals))
+		for _, e := range vals {
+			out = append(out, anyValueToGo(e))
+		}
+		return out
+	case *commonv1.AnyValue_KvlistValue:
+		return kvListToGo(x.KvlistValue.GetValues())
+	}
+	return nil
+}
+
+func marshalStruct(fields map[string]any) ([]byte, error) {
+	s, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(s)
+}
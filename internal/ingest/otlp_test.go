@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+func strAttr(k, v string) *commonv1.KeyValue {
+	return &commonv1.KeyValue{
+		Key:   k,
+		Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: v}},
+	}
+}
+
+func intAttr(k string, v int64) *commonv1.KeyValue {
+	return &commonv1.KeyValue{
+		Key:   k,
+		Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_IntValue{IntValue: v}},
+	}
+}
+
+func sampleLogs() *logsv1.LogsData {
+	return &logsv1.LogsData{
+		ResourceLogs: []*logsv1.ResourceLogs{{
+			Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{
+				strAttr("host.name", "node-a"),
+				strAttr("service.name", "checkout"),
+				intAttr("process.pid", 4242),
+			}},
+			ScopeLogs: []*logsv1.ScopeLogs{{
+				Scope: &commonv1.InstrumentationScope{Name: "otel-go", Version: "1.0"},
+				LogRecords: []*logsv1.LogRecord{
+					{
+						TimeUnixNano:   1_700_000_000_000_000_000,
+						SeverityNumber: logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR2,
+						Body:           &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: "payment failed"}},
+						Attributes:     []*commonv1.KeyValue{strAttr("order.id", "o-1"), intAttr("retries", 3)},
+						TraceId:        []byte{0xde, 0xad, 0xbe, 0xef},
+					},
+					{
+						SeverityText: "warning",
+						Attributes:   []*commonv1.KeyValue{strAttr(OTLPTopicAttribute, "payments")},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+func TestOTLPToEnvelopes_Mapping(t *testing.T) {
+	envs := OTLPToEnvelopes(sampleLogs())
+	if len(envs) != 2 {
+		t.Fatalf("got %d envelopes, want 2", len(envs))
+	}
+
+	e := envs[0]
+	if e.GetTopic() != DefaultOTLPTopic {
+		t.Errorf("Topic = %q, want %q", e.GetTopic(), DefaultOTLPTopic)
+	}
+	if e.GetHost() != "node-a" || e.GetService() != "checkout" || e.GetPid() != 4242 {
+		t.Errorf("host/service/pid = %q/%q/%d", e.GetHost(), e.GetService(), e.GetPid())
+	}
+	if e.GetLevel() != logging.LogLevel_LOG_LEVEL_ERROR {
+		t.Errorf("Level = %v, want ERROR", e.GetLevel())
+	}
+	if e.GetSummary() != "payment failed" {
+		t.Errorf("Summary = %q", e.GetSummary())
+	}
+	if e.GetCorrelationId() != "deadbeef" {
+		t.Errorf("CorrelationId = %q, want %q", e.GetCorrelationId(), "deadbeef")
+	}
+	if got := e.GetTimestamp().AsTime().UnixNano(); got != 1_700_000_000_000_000_000 {
+		t.Errorf("Timestamp = %d", got)
+	}
+	if e.GetType() != StructPayloadType {
+		t.Fatalf("Type = %q, want %q", e.GetType(), StructPayloadType)
+	}
+
+	var s structpb.Struct
+	if err := proto.Unmarshal(e.GetPayload(), &s); err != nil {
+		t.Fatalf("payload is not a Struct: %v", err)
+	}
+	attrs := s.AsMap()["attributes"].(map[string]any)
+	if attrs["order.id"] != "o-1" || attrs["retries"] != float64(3) {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+
+	if got := envs[1].GetTopic(); got != "payments" {
+		t.Errorf("topic attribute not honored: got %q", got)
+	}
+	if got := envs[1].GetLevel(); got != logging.LogLevel_LOG_LEVEL_WARN {
+		t.Errorf("severity_text fallback: got %v, want WARN", got)
+	}
+}
+
+func TestOTLPHandler(t *testing.T) {
+	body, err := proto.Marshal(sampleLogs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*logging.LogEnvelope
+	h := OTLPHandler(func(env *logging.LogEnvelope) { got = append(got, env) })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %q", rec.Code, rec.Body.String())
+	}
+	if len(got) != 2 {
+		t.Fatalf("handled %d envelopes, want 2", len(got))
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("JSON request status = %d, want %d", rec.Code, http.StatusUnsupportedMediaType)
+	}
+
+	// A gzip body inflating past the limit is refused, not truncated.
+	var gzBody bytes.Buffer
+	gz := gzip.NewWriter(&gzBody)
+	if _, err := gz.Write(make([]byte, maxOTLPBody+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/v1/logs", &gzBody)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized gzip request status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,460 @@
+/*******************************************************************************
+*  internal/ingest/syslog.go
+*
+*  Syslog listener for legacy daemons that cannot speak protolog natively.
+*  Messages in RFC 5424 or BSD (RFC 3164) format are received over UDP or TCP
+*  and converted into LogEnvelopes that feed the same pipeline as the ZMQ SUB
+*  loop.
+*******************************************************************************/
+
+package ingest
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// SyslogMessage is a parsed syslog message. Fields absent from the wire (or
+// sent as the RFC 5424 NILVALUE "-") are left empty.
+type SyslogMessage struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+
+	Hostname string
+	AppName  string
+	ProcID   string
+	MsgID    string
+
+	// StructuredData maps SD-ID -> param name -> value (RFC 5424 only).
+	StructuredData map[string]map[string]string
+
+	Message string
+}
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// SyslogTopicPrefix is prepended to the facility name to form the topic.
+const SyslogTopicPrefix = "syslog."
+
+// maxSyslogFrame bounds a single TCP frame.
+const maxSyslogFrame = 64 << 10
+
+// maxOctetDigits bounds the octet count of a framed message, which cannot
+// exceed maxSyslogFrame anyway.
+const maxOctetDigits = 10
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var syslogSeverities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+var errSyslogFormat = errors.New("malformed syslog message")
+
+/*******************************************************************************
+*  PARSING
+*******************************************************************************/
+
+// ParseSyslog parses a single syslog message. RFC 5424 is detected by the
+// version digit following the PRI; everything else is treated as RFC 3164.
+func ParseSyslog(b []byte) (*SyslogMessage, error) {
+	b = bytes.TrimRight(b, "\r\n\x00")
+
+	if len(b) < 3 || b[0] != '<' {
+		return nil, fmt.Errorf("%w: missing PRI", errSyslogFormat)
+	}
+	end := bytes.IndexByte(b, '>')
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("%w: bad PRI", errSyslogFormat)
+	}
+	pri, err := strconv.Atoi(string(b[1:end]))
+	if err != nil || pri > 191 {
+		return nil, fmt.Errorf("%w: bad PRI %q", errSyslogFormat, b[1:end])
+	}
+
+	m := &SyslogMessage{Facility: pri / 8, Severity: pri % 8}
+	rest := b[end+1:]
+
+	if len(rest) >= 2 && rest[0] == '1' && rest[1] == ' ' {
+		err = parseRFC5424(m, string(rest[2:]))
+	} else {
+		parseRFC3164(m, string(rest), time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parseRFC5424(m *SyslogMessage, s string) error {
+	var fields [5]string
+	for i := range fields {
+		var ok bool
+		fields[i], s, ok = strings.Cut(s, " ")
+		if !ok && i < len(fields)-1 {
+			return fmt.Errorf("%w: truncated RFC 5424 header", errSyslogFormat)
+		}
+	}
+
+	if ts := nilValue(fields[0]); ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return fmt.Errorf("%w: bad timestamp %q", errSyslogFormat, ts)
+		}
+		m.Timestamp = t
+	}
+	m.Hostname = nilValue(fields[1])
+	m.AppName = nilValue(fields[2])
+	m.ProcID = nilValue(fields[3])
+	m.MsgID = nilValue(fields[4])
+
+	sd, rest, err := parseStructuredData(s)
+	if err != nil {
+		return err
+	}
+	m.StructuredData = sd
+
+	rest = strings.TrimPrefix(rest, " ")
+	rest = strings.TrimPrefix(rest, "\ufeff") // optional UTF-8 BOM
+	m.Message = rest
+	return nil
+}
+
+// parseStructuredData consumes the STRUCTURED-DATA part of an RFC 5424
+// message and returns the remainder.
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(s, "-") {
+		return nil, s[1:], nil
+	}
+
+	sd := map[string]map[string]string{}
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+
+		idEnd := strings.IndexAny(s, " ]")
+		if idEnd < 0 {
+			return nil, "", fmt.Errorf("%w: unterminated SD-ELEMENT", errSyslogFormat)
+		}
+		params := map[string]string{}
+		sd[s[:idEnd]] = params
+		s = s[idEnd:]
+
+		for strings.HasPrefix(s, " ") {
+			s = s[1:]
+			eq := strings.Index(s, `="`)
+			if eq < 0 {
+				return nil, "", fmt.Errorf("%w: bad SD-PARAM", errSyslogFormat)
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+
+			var val strings.Builder
+			closed := false
+			for i := 0; i < len(s); i++ {
+				c := s[i]
+				if c == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0 {
+					val.WriteByte(s[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					s = s[i+1:]
+					closed = true
+					break
+				}
+				val.WriteByte(c)
+			}
+			if !closed {
+				return nil, "", fmt.Errorf("%w: unterminated SD-PARAM value", errSyslogFormat)
+			}
+			params[name] = val.String()
+		}
+
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("%w: unterminated SD-ELEMENT", errSyslogFormat)
+		}
+		s = s[1:]
+	}
+
+	if len(sd) == 0 {
+		return nil, "", fmt.Errorf("%w: missing STRUCTURED-DATA", errSyslogFormat)
+	}
+	return sd, s, nil
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss HOST TAG[PID]: MSG". BSD syslog is
+// loosely specified, so anything that does not fit is kept as the message.
+func parseRFC3164(m *SyslogMessage, s string, now time.Time) {
+	if len(s) >= 16 && s[15] == ' ' {
+		if t, err := time.ParseInLocation(time.Stamp, s[:15], time.Local); err == nil {
+			// No year on the wire: assume the current one, unless that puts
+			// the message in the future (year roll-over).
+			t = t.AddDate(now.Year(), 0, 0)
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = t
+			s = s[16:]
+
+			// A hostname only follows a valid timestamp; a token ending in
+			// ':' or containing '[' is the tag instead.
+			if host, rest, ok := strings.Cut(s, " "); ok &&
+				!strings.HasSuffix(host, ":") && !strings.Contains(host, "[") {
+				m.Hostname = host
+				s = rest
+			}
+		}
+	}
+
+	// TAG is at most 32 alphanumerics, optionally followed by [PID], then ':'.
+	if colon := strings.Index(s, ":"); colon > 0 && colon <= 48 && !strings.Contains(s[:colon], " ") {
+		tag := s[:colon]
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			m.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		m.AppName = tag
+		s = strings.TrimPrefix(s[colon+1:], " ")
+	}
+
+	m.Message = s
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+/*******************************************************************************
+*  CONVERSION
+*******************************************************************************/
+
+// FacilityName returns the conventional keyword for the message facility.
+func (m *SyslogMessage) FacilityName() string {
+	if m.Facility >= 0 && m.Facility < len(syslogFacilities) {
+		return syslogFacilities[m.Facility]
+	}
+	return strconv.Itoa(m.Facility)
+}
+
+// SeverityName returns the conventional keyword for the message severity.
+func (m *SyslogMessage) SeverityName() string {
+	if m.Severity >= 0 && m.Severity < len(syslogSeverities) {
+		return syslogSeverities[m.Severity]
+	}
+	return strconv.Itoa(m.Severity)
+}
+
+// Level maps the syslog severity onto protolog's LogLevel.
+func (m *SyslogMessage) Level() logging.LogLevel {
+	switch {
+	case m.Severity <= 1: // emerg, alert
+		return logging.LogLevel_LOG_LEVEL_FATAL
+	case m.Severity <= 3: // crit, err
+		return logging.LogLevel_LOG_LEVEL_ERROR
+	case m.Severity == 4:
+		return logging.LogLevel_LOG_LEVEL_WARN
+	case m.Severity <= 6: // notice, info
+		return logging.LogLevel_LOG_LEVEL_INFO
+	default:
+		return logging.LogLevel_LOG_LEVEL_DEBUG
+	}
+}
+
+// Envelope converts the message into a LogEnvelope. The topic is
+// "syslog.<facility>"; facility, severity, msgid and structured data are
+// carried as a google.protobuf.Struct payload.
+func (m *SyslogMessage) Envelope() *logging.LogEnvelope {
+	ts := m.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	pid, _ := strconv.ParseInt(m.ProcID, 10, 32)
+
+	env := &logging.LogEnvelope{
+		Topic:     SyslogTopicPrefix + m.FacilityName(),
+		Timestamp: timestamppb.New(ts),
+		Level:     m.Level(),
+		Host:      m.Hostname,
+		Service:   m.AppName,
+		Pid:       int32(pid),
+		Summary:   m.Message,
+	}
+
+	fields := map[string]any{
+		"facility": m.FacilityName(),
+		"severity": m.SeverityName(),
+	}
+	if m.MsgID != "" {
+		fields["msgid"] = m.MsgID
+	}
+	if m.ProcID != "" && pid == 0 {
+		fields["procid"] = m.ProcID
+	}
+	if len(m.StructuredData) > 0 {
+		sd := make(map[string]any, len(m.StructuredData))
+		for id, params := range m.StructuredData {
+			p := make(map[string]any, len(params))
+			for k, v := range params {
+				p[k] = v
+			}
+			sd[id] = p
+		}
+		fields["structured_data"] = sd
+	}
+
+	if payload, err := marshalStruct(fields); err == nil {
+		env.Type = StructPayloadType
+		env.Payload = payload
+	} else {
+		log.Printf("syslog: build payload: %v", err)
+	}
+	return env
+}
+
+/*******************************************************************************
+*  LISTENERS
+*******************************************************************************/
+
+// ServeSyslogUDP reads one syslog message per datagram from conn until it is
+// closed.
+func ServeSyslogUDP(conn net.PacketConn, handle func(*logging.LogEnvelope)) error {
+	buf := make([]byte, maxSyslogFrame)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		m, err := ParseSyslog(buf[:n])
+		if err != nil {
+			log.Printf("syslog: drop datagram from %s: %v", addr, err)
+			continue
+		}
+		handle(m.Envelope())
+	}
+}
+
+// ServeSyslogTCP accepts connections on ln until it is closed. Frames may
+// use either octet counting or newline delimiting (RFC 6587).
+func ServeSyslogTCP(ln net.Listener, handle func(*logging.LogEnvelope)) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveSyslogConn(conn, handle)
+	}
+}
+
+func serveSyslogConn(conn net.Conn, handle func(*logging.LogEnvelope)) {
+	defer conn.Close()
+
+	r := bufio.NewReaderSize(conn, maxSyslogFrame)
+	for {
+		frame, err := readSyslogFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("syslog: connection %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(bytes.TrimSpace(frame)) == 0 {
+			continue
+		}
+		m, err := ParseSyslog(frame)
+		if err != nil {
+			log.Printf("syslog: drop frame from %s: %v", conn.RemoteAddr(), err)
+			continue
+		}
+		handle(m.Envelope())
+	}
+}
+
+// readSyslogFrame reads one octet-counted ("LEN SP MSG") or LF-terminated
+// frame.
+func readSyslogFrame(r *bufio.Reader) ([]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		// Read the count byte by byte so a peer sending only digits
+		// cannot make the reader buffer without bound.
+		n := 0
+		for digits := 0; ; digits++ {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if c == ' ' {
+				break
+			}
+			if c < '0' || c > '9' || digits == maxOctetDigits {
+				return nil, fmt.Errorf("bad octet count")
+			}
+			n = n*10 + int(c-'0')
+		}
+		if n <= 0 || n > maxSyslogFrame {
+			return nil, fmt.Errorf("bad octet count %d", n)
+		}
+		frame := make([]byte, n)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	// ReadSlice returns at most a buffer's worth; keep reading up to
+	// maxSyslogFrame.
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxSyslogFrame {
+			return nil, fmt.Errorf("frame longer than %d bytes", maxSyslogFrame)
+		}
+		line = append(line, chunk...)
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && !(errors.Is(err, io.EOF) && len(line) > 0) {
+			return nil, err
+		}
+		return line, nil
+	}
+}
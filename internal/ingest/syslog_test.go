@@ -0,0 +1,170 @@
+package ingest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+func TestParseSyslog_RFC5424(t *testing.T) {
+	raw := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 ` +
+		`[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][meta seq="\]1\""] ` +
+		"\ufeffAn application event log entry..."
+
+	m, err := ParseSyslog([]byte(raw))
+	if err != nil {
+		t.Fatalf("ParseSyslog returned error: %v", err)
+	}
+
+	if m.Facility != 20 || m.Severity != 5 {
+		t.Errorf("facility/severity = %d/%d, want 20/5", m.Facility, m.Severity)
+	}
+	want := time.Date(2003, 10, 11, 22, 14, 15, 3_000_000, time.UTC)
+	if !m.Timestamp.Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", m.Timestamp, want)
+	}
+	if m.Hostname != "mymachine.example.com" || m.AppName != "evntslog" ||
+		m.ProcID != "1234" || m.MsgID != "ID47" {
+		t.Errorf("unexpected header: %+v", m)
+	}
+	if got := m.StructuredData["exampleSDID@32473"]["eventID"]; got != "1011" {
+		t.Errorf("SD eventID = %q, want %q", got, "1011")
+	}
+	if got := m.StructuredData["meta"]["seq"]; got != `]1"` {
+		t.Errorf("escaped SD value = %q, want %q", got, `]1"`)
+	}
+	if m.Message != "An application event log entry..." {
+		t.Errorf("Message = %q", m.Message)
+	}
+}
+
+func TestParseSyslog_RFC5424Nil(t *testing.T) {
+	m, err := ParseSyslog([]byte("<34>1 - - su - - - 'su root' failed"))
+	if err != nil {
+		t.Fatalf("ParseSyslog returned error: %v", err)
+	}
+	if !m.Timestamp.IsZero() || m.Hostname != "" || m.ProcID != "" || m.StructuredData != nil {
+		t.Errorf("NILVALUE fields not empty: %+v", m)
+	}
+	if m.AppName != "su" || m.Message != "'su root' failed" {
+		t.Errorf("AppName/Message = %q/%q", m.AppName, m.Message)
+	}
+}
+
+func TestParseSyslog_RFC3164(t *testing.T) {
+	m, err := ParseSyslog([]byte("<34>Oct 11 22:14:15 mymachine su[231]: 'su root' failed for lonvick\n"))
+	if err != nil {
+		t.Fatalf("ParseSyslog returned error: %v", err)
+	}
+
+	if m.FacilityName() != "auth" || m.SeverityName() != "crit" {
+		t.Errorf("facility/severity = %s/%s", m.FacilityName(), m.SeverityName())
+	}
+	if m.Timestamp.Month() != time.October || m.Timestamp.Day() != 11 || m.Timestamp.Hour() != 22 {
+		t.Errorf("Timestamp = %v", m.Timestamp)
+	}
+	if m.Hostname != "mymachine" || m.AppName != "su" || m.ProcID != "231" {
+		t.Errorf("host/app/pid = %q/%q/%q", m.Hostname, m.AppName, m.ProcID)
+	}
+	if m.Message != "'su root' failed for lonvick" {
+		t.Errorf("Message = %q", m.Message)
+	}
+}
+
+func TestParseSyslog_Malformed(t *testing.T) {
+	for _, raw := range []string{"", "no pri", "<999>1 - - - - - -", "<13>1 - host app"} {
+		if _, err := ParseSyslog([]byte(raw)); err == nil {
+			t.Errorf("ParseSyslog(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestSyslogMessage_Envelope(t *testing.T) {
+	m, err := ParseSyslog([]byte(`<11>1 2024-01-02T03:04:05Z web01 nginx 77 - [req id="9"] upstream timed out`))
+	if err != nil {
+		t.Fatalf("ParseSyslog returned error: %v", err)
+	}
+	env := m.Envelope()
+
+	if env.GetTopic() != "syslog.user" {
+		t.Errorf("Topic = %q, want %q", env.GetTopic(), "syslog.user")
+	}
+	if env.GetLevel() != logging.LogLevel_LOG_LEVEL_ERROR {
+		t.Errorf("Level = %v, want ERROR", env.GetLevel())
+	}
+	if env.GetHost() != "web01" || env.GetService() != "nginx" || env.GetPid() != 77 {
+		t.Errorf("host/service/pid = %q/%q/%d", env.GetHost(), env.GetService(), env.GetPid())
+	}
+	if env.GetSummary() != "upstream timed out" {
+		t.Errorf("Summary = %q", env.GetSummary())
+	}
+
+	var s structpb.Struct
+	if err := proto.Unmarshal(env.GetPayload(), &s); err != nil {
+		t.Fatalf("payload is not a Struct: %v", err)
+	}
+	sd := s.AsMap()["structured_data"].(map[string]any)
+	if sd["req"].(map[string]any)["id"] != "9" {
+		t.Errorf("unexpected structured_data: %v", sd)
+	}
+}
+
+func TestServeSyslogTCP_Framing(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	got := make(chan *logging.LogEnvelope, 4)
+	go func() { _ = ServeSyslogTCP(ln, func(env *logging.LogEnvelope) { got <- env }) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	framed := "<13>1 - h a - - - one"
+	if _, err := conn.Write([]byte("21 " + framed + "<13>Jan  1 00:00:00 h b: two\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	for _, want := range []string{"one", "two"} {
+		select {
+		case env := <-got:
+			if env.GetSummary() != want {
+				t.Errorf("Summary = %q, want %q", env.GetSummary(), want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+}
+
+func TestReadSyslogFrame_Bounds(t *testing.T) {
+	for name, in := range map[string]string{
+		"endless digits":  strings.Repeat("9", 1<<20),
+		"oversized count": "99999 x",
+		"endless line":    strings.Repeat("x", maxSyslogFrame+1) + "\n",
+	} {
+		if _, err := readSyslogFrame(bufio.NewReader(strings.NewReader(in))); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("%s: readSyslogFrame error = %v, want a framing error", name, err)
+		}
+	}
+
+	// Lines longer than the reader's buffer are still read whole.
+	long := strings.Repeat("x", 10000) + "\n"
+	if frame, err := readSyslogFrame(bufio.NewReaderSize(strings.NewReader(long), 16)); err != nil || string(frame) != long {
+		t.Errorf("readSyslogFrame(long line) = %d bytes, %v", len(frame), err)
+	}
+}
@@ -0,0 +1,249 @@
+/*******************************************************************************
+*  internal/ingest/zmq.go
+*
+*  ZMQ SUB ingest. Each configured endpoint gets its own SUB socket so that
+*  receive high-water marks and ingest metrics are tracked per endpoint.
+*
+*  Publishers may prefix each envelope with a topic frame ([topic, envelope]),
+*  which lets ZMQ drop unsubscribed topics before they are decoded. Legacy
+*  single-frame envelopes are still accepted. Messages on the schema topic
+*  carry descriptor sets instead of envelopes (see pkg/descset).
+*******************************************************************************/
+
+package ingest
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/pebbe/zmq4"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Espeer5/protolog/internal/config"
+	"github.com/Espeer5/protolog/pkg/descset"
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Subscriber receives LogEnvelopes on a single ZMQ endpoint.
+type Subscriber struct {
+	ep      config.Endpoint
+	topics  []string
+	sock    *zmq4.Socket
+	schemas SchemaHandler
+
+	received     atomic.Uint64
+	bytes        atomic.Uint64
+	decodeErrors atomic.Uint64
+	recvErrors   atomic.Uint64
+	lastMsgMs    atomic.Int64
+}
+
+// SubscriberOptions holds settings shared by all SUB sockets.
+type SubscriberOptions struct {
+	// Topics are the topic-frame prefixes to subscribe to; empty means all.
+	Topics []string
+
+	// CurveSecretKey is the collector's Z85 secret key, used by endpoints
+	// with Curve set. StartCurveAuth must have been called beforehand.
+	CurveSecretKey string
+
+	// Schemas, if set, receives descriptor sets pushed on the schema
+	// side-channel (see pkg/descset); otherwise such messages are dropped.
+	Schemas SchemaHandler
+}
+
+// Handler consumes a received envelope. clientID is the authenticated CURVE
+// client name, or "" on unauthenticated endpoints.
+type Handler func(env *logging.LogEnvelope, clientID string)
+
+// SchemaHandler consumes a serialized FileDescriptorSet pushed by a
+// publisher. clientID is as for Handler.
+type SchemaHandler func(set []byte, clientID string)
+
+// EndpointStats is a snapshot of a Subscriber's ingest counters.
+type EndpointStats struct {
+	Endpoint     string   `json:"endpoint"`
+	Mode         string   `json:"mode"`
+	RcvHWM       int      `json:"rcv_hwm,omitempty"`
+	Curve        bool     `json:"curve,omitempty"`
+	Topics       []string `json:"topics,omitempty"`
+	Received     uint64   `json:"received"`
+	Bytes        uint64   `json:"bytes"`
+	DecodeErrors uint64   `json:"decode_errors"`
+	RecvErrors   uint64   `json:"recv_errors"`
+	LastMessage  string   `json:"last_message,omitempty"`
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// NewSubscriber creates a SUB socket and binds or connects it according to
+// ep. With no topics it subscribes to everything; otherwise only messages
+// whose topic frame starts with one of the given prefixes are delivered, and
+// single-frame envelopes (which carry no topic frame) are filtered out by ZMQ.
+func NewSubscriber(ep config.Endpoint, opts SubscriberOptions) (*Subscriber, error) {
+	sock, err := zmq4.NewSocket(zmq4.SUB)
+	if err != nil {
+		return nil, fmt.Errorf("create SUB socket: %w", err)
+	}
+
+	if ep.RcvHWM > 0 {
+		if err := sock.SetRcvhwm(ep.RcvHWM); err != nil {
+			_ = sock.Close()
+			return nil, fmt.Errorf("set RCVHWM on %s: %w", ep.Addr, err)
+		}
+	}
+
+	if ep.Curve {
+		if opts.CurveSecretKey == "" {
+			_ = sock.Close()
+			return nil, fmt.Errorf("endpoint %s requires CURVE but no secret key is configured", ep.Addr)
+		}
+		if err := sock.ServerAuthCurve(ZAPDomain, opts.CurveSecretKey); err != nil {
+			_ = sock.Close()
+			return nil, fmt.Errorf("enable CURVE on %s: %w", ep.Addr, err)
+		}
+	}
+
+	filters := opts.Topics
+	if len(filters) == 0 {
+		filters = []string{""}
+	} else if opts.Schemas != nil {
+		filters = append(filters[:len(filters):len(filters)], descset.Topic)
+	}
+	for _, f := range filters {
+		if err := sock.SetSubscribe(f); err != nil {
+			_ = sock.Close()
+			return nil, fmt.Errorf("set SUBSCRIBE %q on %s: %w", f, ep.Addr, err)
+		}
+	}
+
+	if ep.Mode == config.ModeConnect {
+		err = sock.Connect(ep.Addr)
+	} else {
+		err = sock.Bind(ep.Addr)
+	}
+	if err != nil {
+		_ = sock.Close()
+		return nil, fmt.Errorf("%s SUB socket on %s: %w", ep.Mode, ep.Addr, err)
+	}
+
+	return &Subscriber{ep: ep, topics: opts.Topics, sock: sock, schemas: opts.Schemas}, nil
+}
+
+// Run receives envelopes forever, passing each one to handle. It must be
+// called from a single goroutine.
+func (s *Subscriber) Run(handle Handler) {
+	for {
+		parts, clientID, err := s.recv()
+		if err != nil {
+			s.recvErrors.Add(1)
+			log.Printf("recv error on %s: %v", s.ep.Addr, err)
+			continue
+		}
+
+		s.received.Add(1)
+		for _, p := range parts {
+			s.bytes.Add(uint64(len(p)))
+		}
+		s.lastMsgMs.Store(time.Now().UnixMilli())
+
+		if IsSchemaMessage(parts) {
+			if s.schemas != nil {
+				s.schemas(parts[1], clientID)
+			}
+			continue
+		}
+
+		env, err := DecodeFrames(parts)
+		if err != nil {
+			s.decodeErrors.Add(1)
+			log.Printf("failed to decode message from %s: %v", s.ep.Addr, err)
+			continue
+		}
+
+		handle(env, clientID)
+	}
+}
+
+// recv receives one multipart message; on CURVE endpoints it also returns
+// the User-Id assigned by the ZAP handler.
+func (s *Subscriber) recv() ([][]byte, string, error) {
+	if !s.ep.Curve {
+		parts, err := s.sock.RecvMessageBytes(0)
+		return parts, "", err
+	}
+	parts, meta, err := s.sock.RecvMessageBytesWithMetadata(0, "User-Id")
+	return parts, meta["User-Id"], err
+}
+
+// IsSchemaMessage reports whether parts is a schema side-channel message,
+// [descset.Topic, FileDescriptorSet].
+func IsSchemaMessage(parts [][]byte) bool {
+	return len(parts) == 2 && string(parts[0]) == descset.Topic
+}
+
+// DecodeFrames decodes a received ZMQ message, either a legacy single-frame
+// envelope or [topic, envelope]. The topic frame fills in the envelope's
+// topic when the publisher left it empty.
+func DecodeFrames(parts [][]byte) (*logging.LogEnvelope, error) {
+	var topic, data []byte
+	switch len(parts) {
+	case 1:
+		data = parts[0]
+	case 2:
+		topic, data = parts[0], parts[1]
+	default:
+		return nil, fmt.Errorf("unexpected %d-part message", len(parts))
+	}
+
+	var env logging.LogEnvelope
+	if err := proto.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("unmarshal LogEnvelope: %w", err)
+	}
+	if env.GetTopic() == "" && len(topic) > 0 {
+		env.Topic = string(topic)
+	}
+	return &env, nil
+}
+
+// Close closes the underlying socket.
+func (s *Subscriber) Close() error {
+	return s.sock.Close()
+}
+
+// Endpoint returns the endpoint this subscriber was created for.
+func (s *Subscriber) Endpoint() config.Endpoint {
+	return s.ep
+}
+
+// Stats returns a snapshot of the subscriber's counters. It is safe to call
+// concurrently with Run.
+func (s *Subscriber) Stats() EndpointStats {
+	st := EndpointStats{
+		Endpoint:     s.ep.Addr,
+		Mode:         string(s.ep.Mode),
+		RcvHWM:       s.ep.RcvHWM,
+		Curve:        s.ep.Curve,
+		Topics:       s.topics,
+		Received:     s.received.Load(),
+		Bytes:        s.bytes.Load(),
+		DecodeErrors: s.decodeErrors.Load(),
+		RecvErrors:   s.recvErrors.Load(),
+	}
+	if ms := s.lastMsgMs.Load(); ms != 0 {
+		st.LastMessage = time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+	}
+	return st
+}
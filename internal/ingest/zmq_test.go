@@ -0,0 +1,64 @@
+package ingest
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Espeer5/protolog/pkg/descset"
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+func TestDecodeFrames(t *testing.T) {
+	data, err := proto.Marshal(&logging.LogEnvelope{Summary: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Legacy single-frame envelope.
+	env, err := DecodeFrames([][]byte{data})
+	if err != nil {
+		t.Fatalf("single frame: %v", err)
+	}
+	if env.GetSummary() != "hi" || env.GetTopic() != "" {
+		t.Errorf("single frame decoded as %v", env)
+	}
+
+	// Topic frame fills in a missing topic.
+	env, err = DecodeFrames([][]byte{[]byte("alerts"), data})
+	if err != nil {
+		t.Fatalf("topic frame: %v", err)
+	}
+	if env.GetTopic() != "alerts" {
+		t.Errorf("Topic = %q, want %q", env.GetTopic(), "alerts")
+	}
+
+	// ...but never overrides the envelope's own topic.
+	data, _ = proto.Marshal(&logging.LogEnvelope{Topic: "metrics"})
+	env, err = DecodeFrames([][]byte{[]byte("metr"), data})
+	if err != nil {
+		t.Fatalf("topic frame: %v", err)
+	}
+	if env.GetTopic() != "metrics" {
+		t.Errorf("Topic = %q, want %q", env.GetTopic(), "metrics")
+	}
+
+	if _, err := DecodeFrames([][]byte{{1}, {2}, {3}}); err == nil {
+		t.Errorf("3-part message decoded without error")
+	}
+}
+
+func TestIsSchemaMessage(t *testing.T) {
+	if !IsSchemaMessage([][]byte{[]byte(descset.Topic), {1}}) {
+		t.Errorf("schema message not recognized")
+	}
+	for _, parts := range [][][]byte{
+		{[]byte(descset.Topic)},
+		{[]byte("protolog"), {1}},
+		{[]byte(descset.Topic), {1}, {2}},
+	} {
+		if IsSchemaMessage(parts) {
+			t.Errorf("IsSchemaMessage(%q) = true", parts)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*******************************************************************************
+*  internal/memory/lru.go
+*
+*  A small fixed-capacity least-recently-used cache, used to keep expensive
+*  derived data (such as rendered payloads) around for data that is looked at
+*  repeatedly.
+*******************************************************************************/
+
+package memory
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"container/list"
+	"sync"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// LRU maps keys to values, evicting the least recently used entry once it
+// holds capacity entries. It is safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently used; elements hold *lruEntry
+	items    map[K]*list.Element
+
+	hits   uint64
+	misses uint64
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// NewLRU creates an LRU holding at most capacity entries (at least 1).
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &LRU[K, V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[K]*list.Element, capacity),
+	}
+}
+
+// Get returns the value for key and marks it as recently used.
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.hits++
+		c.order.MoveToFront(el)
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+	c.misses++
+	var zero V
+	return zero, false
+}
+
+// Add stores value under key, evicting the least recently used entry if the
+// cache is full.
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Stats returns the number of hits and misses of Get so far.
+func (c *LRU[K, V]) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
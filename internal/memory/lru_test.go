@@ -0,0 +1,35 @@
+package memory
+
+import "testing"
+
+func TestLRU_Eviction(t *testing.T) {
+	c := NewLRU[int, string](2)
+	c.Add(1, "a")
+	c.Add(2, "b")
+
+	// Touch 1 so that 2 becomes the eviction candidate.
+	if v, ok := c.Get(1); !ok || v != "a" {
+		t.Fatalf("Get(1) = %q, %v", v, ok)
+	}
+	c.Add(3, "c")
+
+	if _, ok := c.Get(2); ok {
+		t.Errorf("least recently used entry was not evicted")
+	}
+	if v, ok := c.Get(3); !ok || v != "c" {
+		t.Errorf("Get(3) = %q, %v", v, ok)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+
+	c.Add(1, "A")
+	if v, _ := c.Get(1); v != "A" {
+		t.Errorf("Add did not replace existing value: %q", v)
+	}
+
+	hits, misses := c.Stats()
+	if hits != 3 || misses != 1 {
+		t.Errorf("Stats = %d hits, %d misses; want 3, 1", hits, misses)
+	}
+}
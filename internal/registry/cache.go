@@ -0,0 +1,136 @@
+/*******************************************************************************
+*  internal/registry/cache.go
+*
+*  Rendered-payload cache. Rendering means a descriptor lookup, a dynamic
+*  unmarshal, redaction and a marshal; for payloads that are rendered again
+*  and again (history pages re-read while scrolling), the result is kept in an
+*  LRU keyed by a caller-chosen ID (e.g. the storage row ID).
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"log"
+
+	"github.com/Espeer5/protolog/internal/memory"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Rendered is a rendered payload.
+type Rendered struct {
+	Data      []byte
+	Format    Format // RenderJSON for heuristic renderings
+	Heuristic bool   // Data is a schema-less DecodeWire rendering
+}
+
+// RenderCache caches RenderPayload results.
+type RenderCache struct {
+	reg *Registry
+	lru *memory.LRU[renderKey, Rendered] // nil disables caching
+}
+
+// renderKey identifies a rendering. Entries from before a reload are never
+// hit again, since a reload may make an undecodable type decodable.
+type renderKey struct {
+	id   int64
+	gen  uint64
+	opts RenderOptions
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// RenderPayload renders payload with the version of typeName current at tsMs.
+// Payloads that cannot be decoded with a schema (including those without a
+// type) fall back to the heuristic wire-format rendering, always as JSON;
+// if the type is known, its length-delimited values are blanked there. The
+// zero Rendered is returned for empty payloads.
+func (r *Registry) RenderPayload(typeName string, payload []byte, tsMs int64, o RenderOptions) Rendered {
+	if len(payload) == 0 {
+		return Rendered{}
+	}
+
+	if typeName != "" {
+		b, err := r.RenderAt(typeName, payload, tsMs, o)
+		if err == nil {
+			format := o.Format
+			if o.IsJSON() {
+				format = RenderJSON
+			}
+			return Rendered{Data: b, Format: format}
+		}
+		log.Printf("payload decode failed for type %q: %v", typeName, err)
+
+		// Field rules and the sensitive option cannot be applied to a
+		// payload that does not decode as its type.
+		if r.knownType(typeName, tsMs) {
+			b, err := formatWireBlanked(payload)
+			if err != nil {
+				log.Printf("heuristic payload decode failed: %v", err)
+				return Rendered{}
+			}
+			return Rendered{Data: b, Format: RenderJSON, Heuristic: true}
+		}
+	}
+
+	b, err := r.FormatWire(payload)
+	if err != nil {
+		log.Printf("heuristic payload decode failed: %v", err)
+		return Rendered{}
+	}
+	return Rendered{Data: b, Format: RenderJSON, Heuristic: true}
+}
+
+// knownType reports whether typeName resolves, as of tsMs or now.
+func (r *Registry) knownType(typeName string, tsMs int64) bool {
+	if r == nil {
+		return false
+	}
+	if md, err := r.descriptorAt(typeName, tsMs); md != nil || err != nil {
+		return true
+	}
+	_, err := r.findMessage(typeName)
+	return err == nil
+}
+
+// NewRenderCache creates a cache of up to size renderings of reg's payloads.
+// With size <= 0 nothing is cached.
+func NewRenderCache(reg *Registry, size int) *RenderCache {
+	c := &RenderCache{reg: reg}
+	if size > 0 {
+		c.lru = memory.NewLRU[renderKey, Rendered](size)
+	}
+	return c
+}
+
+// Render is RenderPayload, cached under id, which must uniquely identify
+// typeName, payload and tsMs.
+func (c *RenderCache) Render(id int64, typeName string, payload []byte, tsMs int64, o RenderOptions) Rendered {
+	if c.lru == nil {
+		return c.reg.RenderPayload(typeName, payload, tsMs, o)
+	}
+
+	key := renderKey{id: id, gen: c.reg.gen.Load(), opts: o}
+	if out, ok := c.lru.Get(key); ok {
+		return out
+	}
+	out := c.reg.RenderPayload(typeName, payload, tsMs, o)
+	c.lru.Add(key, out)
+	return out
+}
+
+// Stats returns the cache's hit and miss counts.
+func (c *RenderCache) Stats() (hits, misses uint64) {
+	if c.lru == nil {
+		return 0, 0
+	}
+	return c.lru.Stats()
+}
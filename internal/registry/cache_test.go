@@ -0,0 +1,119 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+// openTree opens a registry over treeSet.
+func openTree(tb testing.TB) *registry.Registry {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "tree.desc")
+	b, err := proto.Marshal(treeSet())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	reg, res := registry.Open([]string{path})
+	if len(res.Errors) > 0 {
+		tb.Fatalf("Open failed: %v", res.Errors)
+	}
+	return reg
+}
+
+func TestRenderCache(t *testing.T) {
+	reg := openTree(t)
+	payload := treePayload(t, reg)
+	cache := registry.NewRenderCache(reg, 16)
+
+	first := cache.Render(1, "tree.Node", payload, 0, registry.RenderOptions{})
+	if first.Heuristic || first.Format != registry.RenderJSON || len(first.Data) == 0 {
+		t.Fatalf("Render = %+v", first)
+	}
+	again := cache.Render(1, "tree.Node", payload, 0, registry.RenderOptions{})
+	if string(again.Data) != string(first.Data) {
+		t.Errorf("cached rendering differs: %s vs %s", again.Data, first.Data)
+	}
+	flat := cache.Render(1, "tree.Node", payload, 0, registry.RenderOptions{Format: registry.RenderFlat})
+	if flat.Format != registry.RenderFlat {
+		t.Errorf("options are not part of the cache key: %+v", flat)
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("Stats = %d hits, %d misses; want 1, 2", hits, misses)
+	}
+
+	// A reload invalidates earlier renderings.
+	reg.Reload()
+	cache.Render(1, "tree.Node", payload, 0, registry.RenderOptions{})
+	if hits, _ := cache.Stats(); hits != 1 {
+		t.Errorf("rendering cached across a reload")
+	}
+
+	// Unknown types fall back to the wire-format rendering.
+	if r := cache.Render(2, "tree.Missing", payload, 0, registry.RenderOptions{}); !r.Heuristic {
+		t.Errorf("unknown type rendered as %+v", r)
+	}
+	if r := registry.NewRenderCache(reg, 0).Render(1, "", nil, 0, registry.RenderOptions{}); r.Data != nil {
+		t.Errorf("empty payload rendered as %+v", r)
+	}
+}
+
+// BenchmarkRenderPayload is the cost of one rendering, paid per client per
+// message before renderings were shared.
+func BenchmarkRenderPayload(b *testing.B) {
+	reg := openTree(b)
+	payload := treePayload(b, reg)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		reg.RenderPayload("tree.Node", payload, 0, registry.RenderOptions{})
+	}
+}
+
+// BenchmarkRenderCache_Hit re-renders a history row that is already cached.
+func BenchmarkRenderCache_Hit(b *testing.B) {
+	reg := openTree(b)
+	payload := treePayload(b, reg)
+	cache := registry.NewRenderCache(reg, 1024)
+	cache.Render(1, "tree.Node", payload, 0, registry.RenderOptions{})
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cache.Render(1, "tree.Node", payload, 0, registry.RenderOptions{})
+	}
+}
+
+// BenchmarkFanout compares rendering a broadcast message for each of 32
+// WebSocket clients with rendering it once per distinct format.
+func BenchmarkFanout(b *testing.B) {
+	reg := openTree(b)
+	payload := treePayload(b, reg)
+	const clients = 32
+
+	b.Run("per-client", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for c := 0; c < clients; c++ {
+				reg.RenderPayload("tree.Node", payload, 0, registry.RenderOptions{})
+			}
+		}
+	})
+	b.Run("once", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rendered := make(map[registry.RenderOptions]registry.Rendered)
+			for c := 0; c < clients; c++ {
+				o := registry.RenderOptions{}
+				if _, ok := rendered[o]; !ok {
+					rendered[o] = reg.RenderPayload("tree.Node", payload, 0, o)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,388 @@
+/*******************************************************************************
+*  internal/registry/compat.go
+*
+*  Schema compatibility checks. New descriptors are diffed against the ones
+*  they replace, message by message and enum by enum, and changes that break
+*  decoding of existing data (field numbers reused or retyped, required fields
+*  added or removed, fields or enum values renumbered) are reported. Depending
+*  on the policy, breaking changes are logged or the update is rejected.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Severity classifies a schema change.
+type Severity string
+
+const (
+	// Breaking changes make existing data decode wrongly or not at all.
+	Breaking Severity = "breaking"
+
+	// Warning changes keep the binary encoding compatible but may break
+	// JSON consumers or invite future breakage.
+	Warning Severity = "warning"
+)
+
+// Change is one difference between two versions of a type.
+type Change struct {
+	Type     string   `json:"type"`            // message or enum full name
+	Field    string   `json:"field,omitempty"` // field or enum value, if any
+	Kind     string   `json:"kind"`            // e.g. "field-type-changed"
+	Severity Severity `json:"severity"`
+	Detail   string   `json:"detail"`
+}
+
+// CompatPolicy decides what happens to updates with breaking changes.
+type CompatPolicy string
+
+const (
+	CompatOff    CompatPolicy = "off"    // no checks
+	CompatWarn   CompatPolicy = "warn"   // log changes, apply the update
+	CompatReject CompatPolicy = "reject" // refuse updates with breaking changes
+)
+
+// IncompatibleError is returned when CompatReject refuses an update.
+type IncompatibleError struct {
+	Changes []Change
+}
+
+/*******************************************************************************
+*  ERRORS
+*******************************************************************************/
+
+// ErrIncompatible matches every *IncompatibleError with errors.Is.
+var ErrIncompatible = errors.New("incompatible schema change")
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+func (e *IncompatibleError) Error() string {
+	var b []string
+	for _, c := range e.Changes {
+		if c.Severity == Breaking {
+			b = append(b, c.String())
+		}
+	}
+	return fmt.Sprintf("%v: %s", ErrIncompatible, strings.Join(b, "; "))
+}
+
+func (e *IncompatibleError) Is(target error) bool { return target == ErrIncompatible }
+
+func (c Change) String() string {
+	name := c.Type
+	if c.Field != "" {
+		name += "." + c.Field
+	}
+	return fmt.Sprintf("%s %s: %s", name, c.Kind, c.Detail)
+}
+
+// ParseCompatPolicy validates a policy name.
+func ParseCompatPolicy(s string) (CompatPolicy, error) {
+	switch p := CompatPolicy(s); p {
+	case CompatOff, CompatWarn, CompatReject:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown compatibility policy %q (want off, warn or reject)", s)
+}
+
+// SetCompatPolicy sets how reloads and uploads treat breaking changes. The
+// default is CompatWarn.
+func (r *Registry) SetCompatPolicy(p CompatPolicy) {
+	r.mu.Lock()
+	r.compat = p
+	r.mu.Unlock()
+}
+
+// policy returns the compatibility policy.
+func (r *Registry) policy() CompatPolicy {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.compatPolicy()
+}
+
+// compatPolicy is policy for callers holding r.mu.
+func (r *Registry) compatPolicy() CompatPolicy {
+	if r.compat == "" {
+		return CompatWarn
+	}
+	return r.compat
+}
+
+// HasBreaking reports whether changes include a breaking change.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Severity == Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckSet reports how the types in set differ from the loaded ones they
+// would replace, without changing the registry. Types declared in loaded
+// files that set replaces (by file name) but no longer declares are
+// reported as removed. The set must pass Validate.
+func (r *Registry) CheckSet(set *descriptorpb.FileDescriptorSet) ([]Change, error) {
+	if err := r.Validate(set); err != nil {
+		return nil, err
+	}
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.GetFile()))
+	order := make([]string, 0, len(set.GetFile()))
+	paths := make(map[string]bool, len(set.GetFile()))
+	for _, fdp := range set.GetFile() {
+		protos[fdp.GetName()] = fdp
+		order = append(order, fdp.GetName())
+		paths[fdp.GetName()] = true
+	}
+
+	current := r.files.Load()
+	if current == nil {
+		current = new(protoregistry.Files)
+	}
+	var res ReloadResult
+	files := buildFiles(protos, order, chainResolver{
+		excludingResolver{files: current, exclude: paths},
+		protoregistry.GlobalFiles,
+	}, &res)
+	if len(res.Errors) > 0 {
+		return nil, errors.New(strings.Join(res.Errors, "; "))
+	}
+	return CompareFiles(current, files, paths), nil
+}
+
+// CompareSets diffs two self-contained descriptor sets, e.g. the old and new
+// builds of a schema. Imports missing from a set resolve against the files
+// linked into the collector (well-known types).
+func CompareSets(old, new *descriptorpb.FileDescriptorSet) ([]Change, error) {
+	var files [2]*protoregistry.Files
+	for i, set := range []*descriptorpb.FileDescriptorSet{old, new} {
+		protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.GetFile()))
+		order := make([]string, 0, len(set.GetFile()))
+		for _, fdp := range set.GetFile() {
+			protos[fdp.GetName()] = fdp
+			order = append(order, fdp.GetName())
+		}
+		var res ReloadResult
+		files[i] = buildFiles(protos, order, protoregistry.GlobalFiles, &res)
+		if len(res.Errors) > 0 {
+			return nil, errors.New(strings.Join(res.Errors, "; "))
+		}
+	}
+	return CompareFiles(files[0], files[1], nil), nil
+}
+
+// CompareFiles diffs the messages and enums of old against those of new,
+// matching types by full name. If paths is non-nil, only types declared in
+// those files of old are compared.
+func CompareFiles(old, new *protoregistry.Files, paths map[string]bool) []Change {
+	var changes []Change
+	inScope := func(fd protoreflect.FileDescriptor) bool { return paths == nil || paths[fd.Path()] }
+
+	old.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		if !inScope(fd) {
+			return true
+		}
+		forEachDecl(fd, func(d protoreflect.Descriptor) {
+			nd, err := new.FindDescriptorByName(d.FullName())
+			if err != nil {
+				if md, ok := d.(protoreflect.MessageDescriptor); ok && md.IsMapEntry() {
+					return
+				}
+				changes = append(changes, Change{
+					Type: string(d.FullName()), Kind: "type-removed", Severity: Warning,
+					Detail: fmt.Sprintf("no longer declared (was in %s)", fd.Path()),
+				})
+				return
+			}
+			changes = append(changes, compareDecl(d, nd)...)
+		})
+		return true
+	})
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Type != changes[j].Type {
+			return changes[i].Type < changes[j].Type
+		}
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+func compareDecl(od, nd protoreflect.Descriptor) []Change {
+	switch o := od.(type) {
+	case protoreflect.MessageDescriptor:
+		if n, ok := nd.(protoreflect.MessageDescriptor); ok {
+			return compareMessages(o, n)
+		}
+	case protoreflect.EnumDescriptor:
+		if n, ok := nd.(protoreflect.EnumDescriptor); ok {
+			return compareEnums(o, n)
+		}
+	}
+	return []Change{{
+		Type: string(od.FullName()), Kind: "type-kind-changed", Severity: Breaking,
+		Detail: "changed between message and enum",
+	}}
+}
+
+func compareMessages(o, n protoreflect.MessageDescriptor) []Change {
+	var changes []Change
+	add := func(field, kind string, sev Severity, format string, args ...any) {
+		changes = append(changes, Change{
+			Type: string(o.FullName()), Field: field, Kind: kind, Severity: sev,
+			Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	ofs, nfs := o.Fields(), n.Fields()
+	for i := 0; i < ofs.Len(); i++ {
+		of := ofs.Get(i)
+		nf := nfs.ByNumber(of.Number())
+		name := string(of.Name())
+
+		if nf == nil {
+			switch moved := nfs.ByName(of.Name()); {
+			case moved != nil:
+				add(name, "field-renumbered", Breaking,
+					"field renumbered from %d to %d", of.Number(), moved.Number())
+			case of.Cardinality() == protoreflect.Required:
+				add(name, "required-field-removed", Breaking,
+					"required field %d removed; old readers reject new data", of.Number())
+			case !n.ReservedRanges().Has(of.Number()):
+				add(name, "field-removed", Warning,
+					"field %d removed without reserving its number", of.Number())
+			}
+			continue
+		}
+
+		if nf.Name() != of.Name() {
+			if typeString(of) == typeString(nf) {
+				add(name, "field-renamed", Warning,
+					"field %d renamed to %s; JSON and text output change", of.Number(), nf.Name())
+			} else {
+				add(name, "field-number-reused", Breaking,
+					"field %d reused by %s %s (was %s)", of.Number(), typeString(nf), nf.Name(), typeString(of))
+				continue
+			}
+		}
+
+		if ot, nt := typeString(of), typeString(nf); ot != nt {
+			sev := Breaking
+			if wireClass(of) == wireClass(nf) && of.IsList() == nf.IsList() && of.IsMap() == nf.IsMap() {
+				sev = Warning
+			}
+			add(name, "field-type-changed", sev, "type changed from %s to %s", ot, nt)
+		}
+
+		switch oc, nc := of.Cardinality(), nf.Cardinality(); {
+		case oc == protoreflect.Required && nc != protoreflect.Required:
+			add(name, "required-field-relaxed", Breaking,
+				"field %d is no longer required; old readers reject data without it", of.Number())
+		case oc != protoreflect.Required && nc == protoreflect.Required:
+			add(name, "required-field-added", Breaking,
+				"field %d became required; existing data without it is rejected", of.Number())
+		}
+	}
+
+	for i := 0; i < nfs.Len(); i++ {
+		nf := nfs.Get(i)
+		if ofs.ByNumber(nf.Number()) == nil && nf.Cardinality() == protoreflect.Required {
+			add(string(nf.Name()), "required-field-added", Breaking,
+				"new required field %d; existing data without it is rejected", nf.Number())
+		}
+		if ofs.ByNumber(nf.Number()) == nil && o.ReservedRanges().Has(nf.Number()) {
+			add(string(nf.Name()), "field-number-reused", Breaking,
+				"field %d was reserved", nf.Number())
+		}
+	}
+	return changes
+}
+
+func compareEnums(o, n protoreflect.EnumDescriptor) []Change {
+	var changes []Change
+	add := func(value, kind string, sev Severity, format string, args ...any) {
+		changes = append(changes, Change{
+			Type: string(o.FullName()), Field: value, Kind: kind, Severity: sev,
+			Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	ovs, nvs := o.Values(), n.Values()
+	for i := 0; i < ovs.Len(); i++ {
+		ov := ovs.Get(i)
+		name := string(ov.Name())
+		if nv := nvs.ByName(ov.Name()); nv != nil {
+			if nv.Number() != ov.Number() {
+				add(name, "enum-value-renumbered", Breaking,
+					"value renumbered from %d to %d", ov.Number(), nv.Number())
+			}
+			continue
+		}
+		if nv := nvs.ByNumber(ov.Number()); nv != nil {
+			add(name, "enum-value-renamed", Warning,
+				"value %d renamed to %s; JSON and text output change", ov.Number(), nv.Name())
+			continue
+		}
+		add(name, "enum-value-removed", Warning, "value %d removed", ov.Number())
+	}
+	return changes
+}
+
+// typeString describes a field's type, e.g. "repeated int32",
+// "map<string, demo.Metric>" or "demo.Alert".
+func typeString(fd protoreflect.FieldDescriptor) string {
+	if fd.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", typeString(fd.MapKey()), typeString(fd.MapValue()))
+	}
+	t := fd.Kind().String()
+	switch {
+	case fd.Enum() != nil:
+		t = string(fd.Enum().FullName())
+	case fd.Message() != nil:
+		t = string(fd.Message().FullName())
+	}
+	if fd.IsList() {
+		t = "repeated " + t
+	}
+	return t
+}
+
+// wireClass groups kinds whose encodings are interchangeable on the wire.
+func wireClass(fd protoreflect.FieldDescriptor) string {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Uint32Kind,
+		protoreflect.Uint64Kind, protoreflect.BoolKind, protoreflect.EnumKind:
+		return "varint"
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		return "zigzag"
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+		return "fixed32"
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		return "fixed64"
+	case protoreflect.StringKind, protoreflect.BytesKind:
+		return "bytes"
+	case protoreflect.MessageKind:
+		return "message:" + string(fd.Message().FullName())
+	}
+	return fd.Kind().String()
+}
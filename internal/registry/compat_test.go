@@ -0,0 +1,210 @@
+package registry_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+// orderSet builds a proto2 file "shop.proto" declaring
+//
+//	message Order { required string id = 1; optional int32 qty = 2;
+//	                optional Status status = 3; optional string note = 4; }
+//	enum Status { UNKNOWN = 0; OPEN = 1; CLOSED = 2; }
+//
+// after applying edit to it.
+func orderSet(edit func(order *descriptorpb.DescriptorProto, status *descriptorpb.EnumDescriptorProto)) *descriptorpb.FileDescriptorSet {
+	field := func(name string, num int32, t descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name: proto.String(name), Number: proto.Int32(num), Type: t.Enum(), Label: label.Enum(),
+		}
+	}
+	opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	status := field("status", 3, descriptorpb.FieldDescriptorProto_TYPE_ENUM, opt)
+	status.TypeName = proto.String(".shop.Status")
+
+	order := &descriptorpb.DescriptorProto{
+		Name: proto.String("Order"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REQUIRED),
+			field("qty", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, opt),
+			status,
+			field("note", 4, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt),
+		},
+	}
+	enum := &descriptorpb.EnumDescriptorProto{Name: proto.String("Status")}
+	for i, v := range []string{"UNKNOWN", "OPEN", "CLOSED"} {
+		enum.Value = append(enum.Value, &descriptorpb.EnumValueDescriptorProto{
+			Name: proto.String(v), Number: proto.Int32(int32(i)),
+		})
+	}
+	if edit != nil {
+		edit(order, enum)
+	}
+
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:        proto.String("shop.proto"),
+		Package:     proto.String("shop"),
+		Syntax:      proto.String("proto2"),
+		MessageType: []*descriptorpb.DescriptorProto{order},
+		EnumType:    []*descriptorpb.EnumDescriptorProto{enum},
+	}}}
+}
+
+func TestCheckSet(t *testing.T) {
+	dir := t.TempDir()
+	b, err := proto.Marshal(orderSet(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shop.desc"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, _ := registry.Open([]string{dir})
+
+	type change struct {
+		Field, Kind string
+		Severity    registry.Severity
+	}
+	cases := []struct {
+		name string
+		edit func(o *descriptorpb.DescriptorProto, e *descriptorpb.EnumDescriptorProto)
+		want []change
+	}{
+		{"unchanged", nil, nil},
+		{"field added", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field = append(o.Field, &descriptorpb.FieldDescriptorProto{
+				Name: proto.String("extra"), Number: proto.Int32(5),
+				Type:  descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(),
+				Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			})
+		}, nil},
+		{"number reused", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field[3].Name = proto.String("count")
+			o.Field[3].Type = descriptorpb.FieldDescriptorProto_TYPE_SINT64.Enum()
+		}, []change{{"note", "field-number-reused", registry.Breaking}}},
+		{"renamed", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field[3].Name = proto.String("comment")
+		}, []change{{"note", "field-renamed", registry.Warning}}},
+		{"compatible type change", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field[1].Type = descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()
+		}, []change{{"qty", "field-type-changed", registry.Warning}}},
+		{"incompatible type change", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field[1].Type = descriptorpb.FieldDescriptorProto_TYPE_FIXED32.Enum()
+		}, []change{{"qty", "field-type-changed", registry.Breaking}}},
+		{"required removed", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field = o.Field[1:]
+		}, []change{{"id", "required-field-removed", registry.Breaking}}},
+		{"required relaxed", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field[0].Label = descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+		}, []change{{"id", "required-field-relaxed", registry.Breaking}}},
+		{"removed without reserving", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field = o.Field[:3]
+		}, []change{{"note", "field-removed", registry.Warning}}},
+		{"removed and reserved", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field = o.Field[:3]
+			o.ReservedRange = []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(4), End: proto.Int32(5)}}
+		}, nil},
+		{"field renumbered", func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+			o.Field[3].Number = proto.Int32(9)
+		}, []change{{"note", "field-renumbered", registry.Breaking}}},
+		{"enum renumbered", func(_ *descriptorpb.DescriptorProto, e *descriptorpb.EnumDescriptorProto) {
+			e.Value[1].Number = proto.Int32(2)
+			e.Value[2].Number = proto.Int32(1)
+		}, []change{
+			{"CLOSED", "enum-value-renumbered", registry.Breaking},
+			{"OPEN", "enum-value-renumbered", registry.Breaking},
+		}},
+		{"enum value renamed", func(_ *descriptorpb.DescriptorProto, e *descriptorpb.EnumDescriptorProto) {
+			e.Value[2].Name = proto.String("DONE")
+		}, []change{{"CLOSED", "enum-value-renamed", registry.Warning}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := reg.CheckSet(orderSet(tc.edit))
+			if err != nil {
+				t.Fatalf("CheckSet failed: %v", err)
+			}
+			var got []change
+			for _, c := range changes {
+				got = append(got, change{c.Field, c.Kind, c.Severity})
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("changes = %+v, want %+v", changes, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompatPolicy_Reject(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shop.desc")
+	write := func(set *descriptorpb.FileDescriptorSet) {
+		b, err := proto.Marshal(set)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(orderSet(nil))
+
+	reg, _ := registry.Open([]string{path})
+	if err := reg.EnableUploads(filepath.Join(dir, "uploads")); err != nil {
+		t.Fatal(err)
+	}
+	reg.SetCompatPolicy(registry.CompatReject)
+
+	retyped := orderSet(func(o *descriptorpb.DescriptorProto, _ *descriptorpb.EnumDescriptorProto) {
+		o.Field[3].Type = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE.Enum()
+	})
+
+	// Uploads replacing shop.proto are refused.
+	_, err := reg.AddSet("shop", retyped)
+	var incompatible *registry.IncompatibleError
+	if !errors.As(err, &incompatible) || !errors.Is(err, registry.ErrIncompatible) {
+		t.Fatalf("AddSet = %v, want IncompatibleError", err)
+	}
+	if len(incompatible.Changes) != 1 || incompatible.Changes[0].Kind != "field-type-changed" {
+		t.Errorf("changes = %+v", incompatible.Changes)
+	}
+
+	// So are reloads; the previous version of shop.proto stays in place.
+	write(retyped)
+	res := reg.Reload()
+	if len(res.Errors) == 0 || !registry.HasBreaking(res.Compat) || !reflect.DeepEqual(res.Rejected, []string{"shop.proto"}) {
+		t.Errorf("Reload = %+v, want rejected breaking change", res)
+	}
+	ti, err := reg.DescribeType("shop.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ti.Fields[3].Type; got != "string" {
+		t.Errorf("note has type %q after rejected reload, want string", got)
+	}
+
+	// Unrelated updates still go through while shop.desc stays rejected.
+	other := makeSet("other", map[string][]string{"Note": {"text"}})
+	res, err = reg.AddSet("other", other)
+	if err != nil || !reflect.DeepEqual(res.Added, []string{"other.Note"}) {
+		t.Errorf("AddSet(other) = %+v, %v; want other.Note added", res, err)
+	}
+	if ti, err := reg.DescribeType("shop.Order"); err != nil || ti.Fields[3].Type != "string" {
+		t.Errorf("shop.Order changed by an unrelated update: %+v, %v", ti, err)
+	}
+
+	// Under warn the same reload goes through and reports the change.
+	reg.SetCompatPolicy(registry.CompatWarn)
+	res = reg.Reload()
+	if len(res.Errors) != 0 || !reflect.DeepEqual(res.Changed, []string{"shop.Order"}) || !registry.HasBreaking(res.Compat) {
+		t.Errorf("Reload = %+v, want applied breaking change", res)
+	}
+}
@@ -0,0 +1,239 @@
+/*******************************************************************************
+*  internal/registry/compile.go
+*
+*  .proto sources compiled in-process. Every .proto file below a source root
+*  is compiled on each reload (imports resolve against the roots, then the
+*  extra import paths, then the files linked into the collector) and loaded
+*  alongside the descriptor sets, so a registry can be fed the same sources
+*  that protoc would otherwise have to turn into a .desc first.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bufbuild/protocompile"
+	"github.com/bufbuild/protocompile/reporter"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// ProtoSources configures the .proto sources compiled into the registry.
+type ProtoSources struct {
+	// Roots are directories whose .proto files (recursively) are compiled.
+	// File names are relative to their root, so imports are written as
+	// they would be with protoc -I ROOT.
+	Roots []string
+
+	// ImportPaths are further directories searched for imports. Their
+	// files are loaded only as far as the roots' files import them.
+	ImportPaths []string
+}
+
+// CompileError is a problem found compiling a .proto source.
+type CompileError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+func (e CompileError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// SetProtoSources compiles the .proto files of src into the registry from
+// now on and reloads it. Call it before Watch, which then also watches the
+// source directories.
+func (r *Registry) SetProtoSources(src ProtoSources) ReloadResult {
+	r.mu.Lock()
+	r.protos = src
+	r.mu.Unlock()
+	return r.Reload()
+}
+
+// protoDirs returns the directories holding .proto sources.
+func (r *Registry) protoDirs() []string {
+	return append(append([]string(nil), r.protos.Roots...), r.protos.ImportPaths...)
+}
+
+// CompileProtos compiles every .proto file below src.Roots and returns the
+// resulting file descriptors, including source info, in dependency order:
+// the roots' files and whatever they import, except files linked into the
+// collector. Files that fail to compile are left out and reported, with
+// file and line, in the returned errors.
+func CompileProtos(src ProtoSources) ([]*descriptorpb.FileDescriptorProto, []CompileError) {
+	var (
+		names []string
+		errs  []CompileError
+		seen  = make(map[string]bool)
+	)
+	for _, root := range src.Roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || filepath.Ext(path) != ".proto" {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if name := filepath.ToSlash(rel); !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, CompileError{File: root, Message: err.Error()})
+		}
+	}
+	if len(names) == 0 {
+		return nil, errs
+	}
+	sort.Strings(names)
+
+	reported := make(map[CompileError]bool)
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.CompositeResolver{
+			protocompile.WithStandardImports(&protocompile.SourceResolver{
+				ImportPaths: append(append([]string(nil), src.Roots...), src.ImportPaths...),
+			}),
+			protocompile.ResolverFunc(linkedFile),
+		},
+		SourceInfoMode: protocompile.SourceInfoStandard,
+		Reporter: reporter.NewReporter(func(err reporter.ErrorWithPos) error {
+			pos := err.GetPosition()
+			ce := CompileError{
+				File:    pos.Filename,
+				Line:    pos.Line,
+				Column:  pos.Col,
+				Message: err.Unwrap().Error(),
+			}
+			// Errors in an imported file recur for each importer.
+			if !reported[ce] {
+				reported[ce] = true
+				errs = append(errs, ce)
+			}
+			return nil // keep going to report every error
+		}, nil),
+	}
+
+	// Files are compiled one at a time so that a broken file only costs
+	// itself and its importers.
+	var (
+		out  []*descriptorpb.FileDescriptorProto
+		done = make(map[string]bool)
+	)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if done[fd.Path()] {
+			return
+		}
+		done[fd.Path()] = true
+		if _, err := protoregistry.GlobalFiles.FindFileByPath(fd.Path()); err == nil {
+			return
+		}
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		out = append(out, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, name := range names {
+		files, err := compiler.Compile(context.Background(), name)
+		if err != nil {
+			if !errors.Is(err, reporter.ErrInvalidSource) {
+				errs = append(errs, CompileError{File: name, Message: err.Error()})
+			}
+			continue
+		}
+		add(files[0])
+	}
+	return out, errs
+}
+
+// linkedFile resolves imports of files linked into the collector (such as
+// protolog/options.proto) that are not found on the import paths.
+func linkedFile(path string) (protocompile.SearchResult, error) {
+	fd, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	if err != nil {
+		return protocompile.SearchResult{}, err
+	}
+	return protocompile.SearchResult{Desc: fd}, nil
+}
+
+// readProtoSources adds the compiled .proto sources to protos, after the
+// descriptor sets: a file already loaded from a set keeps that definition.
+func (r *Registry) readProtoSources(protos map[string]*descriptorpb.FileDescriptorProto,
+	order []string, origins map[string]string, res *ReloadResult) []string {
+	if len(r.protos.Roots) == 0 {
+		return order
+	}
+
+	compiled, errs := CompileProtos(r.protos)
+	for _, e := range errs {
+		res.CompileErrors = append(res.CompileErrors, e)
+		res.Errors = append(res.Errors, "compile "+e.Error())
+	}
+
+	for _, fdp := range compiled {
+		name := fdp.GetName()
+		if prev, ok := protos[name]; ok {
+			if !equalIgnoringSourceInfo(prev, fdp) {
+				res.Errors = append(res.Errors, fmt.Sprintf(
+					"compiled file %q differs from the descriptor set %q; keeping the descriptor set",
+					name, origins[name]))
+			}
+			continue
+		}
+		protos[name] = fdp
+		origins[name] = sourcePath(r.protoDirs(), name)
+		order = append(order, name)
+	}
+	return order
+}
+
+// sourcePath returns where the file called name was found on dirs.
+func sourcePath(dirs []string, name string) string {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return name
+}
+
+func equalIgnoringSourceInfo(a, b *descriptorpb.FileDescriptorProto) bool {
+	a, b = proto.Clone(a).(*descriptorpb.FileDescriptorProto), proto.Clone(b).(*descriptorpb.FileDescriptorProto)
+	a.SourceCodeInfo, b.SourceCodeInfo = nil, nil
+	return proto.Equal(a, b)
+}
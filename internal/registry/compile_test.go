@@ -0,0 +1,93 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+func writeProto(t *testing.T, path, src string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetProtoSources(t *testing.T) {
+	dir := t.TempDir()
+	root, common := filepath.Join(dir, "src"), filepath.Join(dir, "common")
+
+	writeProto(t, filepath.Join(common, "money/money.proto"), `syntax = "proto3";
+package money;
+message Money { string currency = 1; int64 cents = 2; }
+`)
+	writeProto(t, filepath.Join(root, "shop/order.proto"), `syntax = "proto3";
+package shop;
+import "google/protobuf/timestamp.proto";
+import "money/money.proto";
+
+// An order placed in the shop.
+message Order {
+  string id = 1;
+  money.Money total = 2;
+  google.protobuf.Timestamp placed = 3;
+}
+`)
+	writeProto(t, filepath.Join(root, "shop/broken.proto"), `syntax = "proto3";
+package shop;
+
+message Broken {
+  strin name = 1;
+}
+`)
+	writeProto(t, filepath.Join(root, "shop/uses_broken.proto"), `syntax = "proto3";
+package shop;
+import "shop/broken.proto";
+message UsesBroken { Broken b = 1; }
+`)
+
+	reg, _ := registry.Open(nil)
+	res := reg.SetProtoSources(registry.ProtoSources{
+		Roots:       []string{root},
+		ImportPaths: []string{common},
+	})
+
+	if !reflect.DeepEqual(res.Added, []string{"money.Money", "shop.Order"}) {
+		t.Errorf("Added = %v, want [money.Money shop.Order]", res.Added)
+	}
+	if len(res.CompileErrors) != 1 {
+		t.Fatalf("CompileErrors = %+v, want one", res.CompileErrors)
+	}
+	if e := res.CompileErrors[0]; e.File != "shop/broken.proto" || e.Line != 5 || e.Column != 3 {
+		t.Errorf("compile error at %s:%d:%d, want shop/broken.proto:5:3 (%s)", e.File, e.Line, e.Column, e.Message)
+	}
+
+	ti, err := reg.DescribeType("shop.Order")
+	if err != nil {
+		t.Fatalf("DescribeType failed: %v", err)
+	}
+	if ti.Comment != "An order placed in the shop." {
+		t.Errorf("comment = %q, want the source comment", ti.Comment)
+	}
+	for _, f := range reg.Files() {
+		if f.Name == "shop/order.proto" && f.Source != filepath.Join(root, "shop/order.proto") {
+			t.Errorf("source = %q, want the .proto path", f.Source)
+		}
+	}
+
+	// Fixing the file and reloading picks it up.
+	writeProto(t, filepath.Join(root, "shop/broken.proto"), `syntax = "proto3";
+package shop;
+message Broken { string name = 1; }
+`)
+	res = reg.Reload()
+	if len(res.Errors) != 0 || !reflect.DeepEqual(res.Added, []string{"shop.Broken", "shop.UsesBroken"}) {
+		t.Errorf("reload = %+v, want shop.Broken and shop.UsesBroken added", res)
+	}
+}
@@ -0,0 +1,212 @@
+/*******************************************************************************
+*  internal/registry/describe.go
+*
+*  Type introspection. Describes the structure of a message type (fields,
+*  enums, nested messages, maps, comments) as plain JSON-friendly values, so
+*  that clients can build column pickers, filters and plots without parsing
+*  descriptors themselves.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// maxDescribeDepth bounds how deep nested message fields are expanded.
+const maxDescribeDepth = 16
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// TypeInfo describes a message type.
+type TypeInfo struct {
+	Name    string      `json:"name"`
+	File    string      `json:"file"`
+	Comment string      `json:"comment,omitempty"`
+	Fields  []FieldInfo `json:"fields"`
+}
+
+// FieldInfo describes one field. Message-typed fields (and map values) are
+// expanded into Fields, except for recursive references, which are marked
+// Recursive and only carry TypeName.
+type FieldInfo struct {
+	Name      string      `json:"name"`
+	JSONName  string      `json:"json_name"`
+	Number    int32       `json:"number"`
+	Type      string      `json:"type"`                // scalar kind, "enum", "message", "group" or "map"
+	TypeName  string      `json:"type_name,omitempty"` // for enums and messages
+	Repeated  bool        `json:"repeated,omitempty"`
+	Optional  bool        `json:"optional,omitempty"` // explicit presence
+	Oneof     string      `json:"oneof,omitempty"`
+	Sensitive bool        `json:"sensitive,omitempty"`
+	Comment   string      `json:"comment,omitempty"`
+	Map       *MapInfo    `json:"map,omitempty"`
+	Enum      *EnumInfo   `json:"enum,omitempty"`
+	Fields    []FieldInfo `json:"fields,omitempty"`
+	Recursive bool        `json:"recursive,omitempty"`
+}
+
+// MapInfo describes the key and value of a map field.
+type MapInfo struct {
+	Key   string    `json:"key"`
+	Value FieldInfo `json:"value"`
+}
+
+// EnumInfo describes an enum type.
+type EnumInfo struct {
+	Name    string      `json:"name"`
+	Comment string      `json:"comment,omitempty"`
+	Values  []EnumValue `json:"values"`
+}
+
+// EnumValue is one value of an enum.
+type EnumValue struct {
+	Name    string `json:"name"`
+	Number  int32  `json:"number"`
+	Comment string `json:"comment,omitempty"`
+}
+
+/*******************************************************************************
+*  ERRORS
+*******************************************************************************/
+
+// ErrUnknownType is returned when describing a type the registry cannot find.
+var ErrUnknownType = errors.New("unknown message type")
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// DescribeType describes the current version of the message type typeName.
+func (r *Registry) DescribeType(typeName string) (*TypeInfo, error) {
+	md, err := r.findMessage(typeName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, typeName)
+	}
+	return describeMessage(md), nil
+}
+
+// DescribeTypeAt is DescribeType for the version of typeName that was current
+// at tsMs (Unix milliseconds); see RenderAt.
+func (r *Registry) DescribeTypeAt(typeName string, tsMs int64) (*TypeInfo, error) {
+	md, err := r.descriptorAt(typeName, tsMs)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return r.DescribeType(typeName)
+	}
+	return describeMessage(md), nil
+}
+
+func describeMessage(md protoreflect.MessageDescriptor) *TypeInfo {
+	return &TypeInfo{
+		Name:    string(md.FullName()),
+		File:    md.ParentFile().Path(),
+		Comment: comment(md),
+		Fields:  describeFields(md, []protoreflect.FullName{md.FullName()}),
+	}
+}
+
+// describeFields describes the fields of md; stack holds the message types
+// being expanded, outermost first, to detect recursion.
+func describeFields(md protoreflect.MessageDescriptor, stack []protoreflect.FullName) []FieldInfo {
+	fields := md.Fields()
+	out := make([]FieldInfo, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		out = append(out, describeField(fields.Get(i), stack))
+	}
+	return out
+}
+
+func describeField(fd protoreflect.FieldDescriptor, stack []protoreflect.FullName) FieldInfo {
+	fi := FieldInfo{
+		Name:      string(fd.Name()),
+		JSONName:  fd.JSONName(),
+		Number:    int32(fd.Number()),
+		Repeated:  fd.IsList(),
+		Sensitive: IsSensitive(fd),
+		Comment:   comment(fd),
+	}
+	if od := fd.ContainingOneof(); od != nil && !od.IsSynthetic() {
+		fi.Oneof = string(od.Name())
+	}
+	fi.Optional = fd.HasPresence() && fi.Oneof == "" && fd.Message() == nil && !fd.IsList()
+
+	if fd.IsMap() {
+		fi.Type = "map"
+		fi.Map = &MapInfo{
+			Key:   fd.MapKey().Kind().String(),
+			Value: describeField(fd.MapValue(), stack),
+		}
+		return fi
+	}
+
+	fi.Type = fd.Kind().String()
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		fi.TypeName = string(fd.Enum().FullName())
+		fi.Enum = describeEnum(fd.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		md := fd.Message()
+		fi.TypeName = string(md.FullName())
+		if len(stack) >= maxDescribeDepth || inStack(stack, md.FullName()) {
+			fi.Recursive = true
+			break
+		}
+		fi.Fields = describeFields(md, append(stack[:len(stack):len(stack)], md.FullName()))
+	}
+	return fi
+}
+
+func describeEnum(ed protoreflect.EnumDescriptor) *EnumInfo {
+	values := ed.Values()
+	ei := &EnumInfo{
+		Name:    string(ed.FullName()),
+		Comment: comment(ed),
+		Values:  make([]EnumValue, 0, values.Len()),
+	}
+	for i := 0; i < values.Len(); i++ {
+		v := values.Get(i)
+		ei.Values = append(ei.Values, EnumValue{
+			Name:    string(v.Name()),
+			Number:  int32(v.Number()),
+			Comment: comment(v),
+		})
+	}
+	return ei
+}
+
+func inStack(stack []protoreflect.FullName, name protoreflect.FullName) bool {
+	for _, n := range stack {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// comment returns the leading (or else trailing) comment of d, if the
+// descriptor set was built with source info (protoc --include_source_info).
+func comment(d protoreflect.Descriptor) string {
+	loc := d.ParentFile().SourceLocations().ByDescriptor(d)
+	c := loc.LeadingComments
+	if strings.TrimSpace(c) == "" {
+		c = loc.TrailingComments
+	}
+	return strings.TrimSpace(c)
+}
@@ -0,0 +1,133 @@
+package registry_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+// treeSet describes, with source info:
+//
+//	// A tree node.
+//	message Node {
+//	  string name = 1; // Display name.
+//	  repeated Node children = 2;
+//	  map<string, int64> counts = 3;
+//	  Kind kind = 4;
+//	  optional double weight = 5;
+//	}
+//	enum Kind { KIND_UNSPECIFIED = 0; LEAF = 1; }
+func treeSet() *descriptorpb.FileDescriptorSet {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	rep := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	weight := field("weight", 5, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, opt, "")
+	weight.Proto3Optional = proto.Bool(true)
+	weight.OneofIndex = proto.Int32(0)
+
+	node := &descriptorpb.DescriptorProto{
+		Name: proto.String("Node"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+			field("children", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep, ".tree.Node"),
+			field("counts", 3, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep, ".tree.Node.CountsEntry"),
+			field("kind", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, opt, ".tree.Kind"),
+			weight,
+		},
+		NestedType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("CountsEntry"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+				field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, opt, ""),
+			},
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}},
+		OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("_weight")}},
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("tree.proto"),
+		Package:     proto.String("tree"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{node},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("KIND_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("LEAF"), Number: proto.Int32(1)},
+			},
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{
+				{Path: []int32{4, 0}, Span: []int32{1, 0, 7}, LeadingComments: proto.String(" A tree node.\n")},
+				{Path: []int32{4, 0, 2, 0}, Span: []int32{2, 2, 18}, TrailingComments: proto.String(" Display name.\n")},
+			},
+		},
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fdp}}
+}
+
+func TestDescribeType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.desc")
+	b, err := proto.Marshal(treeSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, res := registry.Open([]string{path})
+	if len(res.Errors) > 0 {
+		t.Fatalf("Open failed: %v", res.Errors)
+	}
+
+	ti, err := reg.DescribeType("tree.Node")
+	if err != nil {
+		t.Fatalf("DescribeType failed: %v", err)
+	}
+	if ti.File != "tree.proto" || ti.Comment != "A tree node." || len(ti.Fields) != 5 {
+		t.Fatalf("DescribeType = %+v", ti)
+	}
+
+	name, children, counts, kind, weight := ti.Fields[0], ti.Fields[1], ti.Fields[2], ti.Fields[3], ti.Fields[4]
+	if name.Type != "string" || name.Number != 1 || name.Comment != "Display name." || name.Optional {
+		t.Errorf("name = %+v", name)
+	}
+	if !children.Repeated || children.Type != "message" || children.TypeName != "tree.Node" || !children.Recursive {
+		t.Errorf("children = %+v", children)
+	}
+	if counts.Type != "map" || counts.Map == nil || counts.Map.Key != "string" ||
+		counts.Map.Value.Type != "int64" || counts.Repeated {
+		t.Errorf("counts = %+v", counts)
+	}
+	if kind.Enum == nil || len(kind.Enum.Values) != 2 || kind.Enum.Values[1].Name != "LEAF" ||
+		kind.Enum.Values[1].Number != 1 {
+		t.Errorf("kind = %+v", kind)
+	}
+	if !weight.Optional || weight.Oneof != "" {
+		t.Errorf("weight = %+v", weight)
+	}
+
+	if _, err := reg.DescribeType("tree.Missing"); !errors.Is(err, registry.ErrUnknownType) {
+		t.Errorf("DescribeType(missing) = %v, want ErrUnknownType", err)
+	}
+}
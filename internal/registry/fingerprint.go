@@ -0,0 +1,242 @@
+/*******************************************************************************
+*  internal/registry/fingerprint.go
+*
+*  Descriptor sets pushed by publishers. Envelopes may carry the fingerprint
+*  of a FileDescriptorSet describing their payload, and publishers push the
+*  set itself on the schema side-channel (see pkg/descset). Sets with unknown
+*  fingerprints are learned by storing their new files as an upload named
+*  after the fingerprint. Learning is bounded in count, total size and rate
+*  (see LearnLimits), as every learned set costs a file and a full reload.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/pkg/descset"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// LearnLimits bounds the descriptor sets publishers can add with Learn.
+type LearnLimits struct {
+	MaxSets  int           // learned sets stored at once
+	MaxBytes int64         // total size of the stored learned sets
+	Interval time.Duration // minimum time between two sets being stored
+}
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// learnedSetPrefix prefixes the upload names of learned descriptor sets.
+const learnedSetPrefix = "fp-"
+
+// DefaultLearnLimits are the limits used until SetLearnLimits is called.
+var DefaultLearnLimits = LearnLimits{MaxSets: 64, MaxBytes: 8 << 20, Interval: time.Second}
+
+/*******************************************************************************
+*  ERRORS
+*******************************************************************************/
+
+var (
+	// ErrLearnLimit is returned by Learn when storing the set would exceed
+	// LearnLimits.MaxSets or LearnLimits.MaxBytes.
+	ErrLearnLimit = errors.New("learned schema limit reached")
+
+	// ErrLearnRate is returned by Learn for a set pushed less than
+	// LearnLimits.Interval after the previous set was stored.
+	ErrLearnRate = errors.New("descriptor sets pushed too often")
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// Learn registers the types in a serialized FileDescriptorSet pushed by a
+// publisher. Files that are already loaded (by name) are skipped, so pushed
+// sets never replace configured descriptors; the remaining files are
+// validated and stored like an upload, within the registry's LearnLimits.
+// It reports whether the fingerprint was new. Uploads must be enabled.
+func (r *Registry) Learn(set []byte) (ReloadResult, bool, error) {
+	fp := descset.Fingerprint(set)
+	if r.KnowsFingerprint(fp) {
+		return ReloadResult{}, false, nil
+	}
+	if r.uploadDir == "" {
+		return ReloadResult{}, false, ErrUploadsDisabled
+	}
+
+	var fds descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(set, &fds); err != nil {
+		return ReloadResult{}, false, fmt.Errorf("unmarshal descriptor set %s: %w", fp, err)
+	}
+
+	current := r.files.Load()
+	if current == nil {
+		current = new(protoregistry.Files)
+	}
+	var fresh descriptorpb.FileDescriptorSet
+	for _, fdp := range fds.GetFile() {
+		if _, err := current.FindFileByPath(fdp.GetName()); err == nil {
+			continue
+		}
+		if _, err := protoregistry.GlobalFiles.FindFileByPath(fdp.GetName()); err == nil {
+			continue
+		}
+		fresh.File = append(fresh.File, fdp)
+	}
+
+	var res ReloadResult
+	if len(fresh.File) > 0 {
+		if err := r.reserveLearn(int64(proto.Size(&fresh))); err != nil {
+			return ReloadResult{}, false, fmt.Errorf("descriptor set %s: %w", fp, err)
+		}
+		var err error
+		if res, err = r.AddSet(learnedSetPrefix+fp, &fresh); err != nil {
+			return ReloadResult{}, false, err
+		}
+	}
+
+	r.fpMu.Lock()
+	if r.known == nil {
+		r.known = make(map[string]bool)
+	}
+	r.known[fp] = true
+	delete(r.missing, fp)
+	r.fpMu.Unlock()
+
+	return res, true, nil
+}
+
+// SetLearnLimits sets the limits Learn enforces. The default is
+// DefaultLearnLimits; zero fields are not limited.
+func (r *Registry) SetLearnLimits(l LearnLimits) {
+	r.fpMu.Lock()
+	r.limits = &l
+	r.fpMu.Unlock()
+}
+
+// reserveLearn checks that a set of size bytes may be stored now and, if
+// so, starts a new LearnLimits.Interval.
+func (r *Registry) reserveLearn(size int64) error {
+	r.fpMu.Lock()
+	defer r.fpMu.Unlock()
+
+	l := DefaultLearnLimits
+	if r.limits != nil {
+		l = *r.limits
+	}
+	now := time.Now()
+	if l.Interval > 0 && now.Sub(r.lastLearn) < l.Interval {
+		return ErrLearnRate
+	}
+
+	sets, total := r.learnedUsage()
+	if l.MaxSets > 0 && sets+1 > l.MaxSets {
+		return fmt.Errorf("%w: %d sets stored", ErrLearnLimit, sets)
+	}
+	if l.MaxBytes > 0 && total+size > l.MaxBytes {
+		return fmt.Errorf("%w: %d bytes stored", ErrLearnLimit, total)
+	}
+	r.lastLearn = now
+	return nil
+}
+
+// learnedUsage counts the learned sets in the upload directory and their
+// total size.
+func (r *Registry) learnedUsage() (sets int, total int64) {
+	entries, err := os.ReadDir(r.uploadDir)
+	if err != nil {
+		return 0, 0
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), learnedSetPrefix) || !strings.HasSuffix(e.Name(), ".desc") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			sets++
+			total += info.Size()
+		}
+	}
+	return sets, total
+}
+
+// KnowsFingerprint reports whether the descriptor set with fingerprint fp
+// has been learned.
+func (r *Registry) KnowsFingerprint(fp string) bool {
+	r.fpMu.Lock()
+	defer r.fpMu.Unlock()
+	return r.known[fp]
+}
+
+// NoteFingerprint is called for envelopes carrying a fingerprint. If the
+// set is unknown and typeName cannot be decoded either, it logs the first
+// occurrence so a publisher that never pushes its descriptors is noticed.
+func (r *Registry) NoteFingerprint(fp, typeName string) {
+	if r == nil || fp == "" || r.KnowsFingerprint(fp) {
+		return
+	}
+	if _, err := r.findMessage(typeName); err == nil {
+		return
+	}
+
+	r.fpMu.Lock()
+	first := !r.missing[fp]
+	if first {
+		if r.missing == nil {
+			r.missing = make(map[string]bool)
+		}
+		r.missing[fp] = true
+	}
+	r.fpMu.Unlock()
+
+	if first {
+		log.Printf("schema registry: type %q has unknown schema fingerprint %s; waiting for its descriptors", typeName, fp)
+	}
+}
+
+// loadFingerprints marks the sets learned before a restart as known.
+func (r *Registry) loadFingerprints() {
+	entries, err := os.ReadDir(r.uploadDir)
+	if err != nil {
+		return
+	}
+
+	r.fpMu.Lock()
+	defer r.fpMu.Unlock()
+	if r.known == nil {
+		r.known = make(map[string]bool)
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".desc")
+		if fp, ok := strings.CutPrefix(name, learnedSetPrefix); ok && name != e.Name() {
+			r.known[fp] = true
+		}
+	}
+}
+
+// forgetFingerprint is called when the uploaded set name is removed.
+func (r *Registry) forgetFingerprint(name string) {
+	if fp, ok := strings.CutPrefix(name, learnedSetPrefix); ok {
+		r.fpMu.Lock()
+		delete(r.known, fp)
+		r.fpMu.Unlock()
+	}
+}
@@ -0,0 +1,122 @@
+package registry_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/Espeer5/protolog/internal/registry"
+	"github.com/Espeer5/protolog/pkg/descset"
+)
+
+func TestLearn(t *testing.T) {
+	dir := t.TempDir()
+	writeSet(t, filepath.Join(dir, "base.desc"), "base", map[string][]string{"M": {"x"}})
+
+	reg, _ := registry.Open([]string{filepath.Join(dir, "base.desc")})
+	if err := reg.EnableUploads(filepath.Join(dir, "uploads")); err != nil {
+		t.Fatalf("EnableUploads failed: %v", err)
+	}
+
+	// The pushed set repeats base.proto, with a different definition of
+	// base.M; the loaded file must win.
+	set := makeSet("base", map[string][]string{"M": {"x", "spoofed"}})
+	set.File = append(set.File, makeSet("robot", map[string][]string{"Pose": {"frame"}}, "base.proto").File...)
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := descset.Fingerprint(b)
+
+	if reg.KnowsFingerprint(fp) {
+		t.Fatalf("fingerprint known before it was pushed")
+	}
+	res, learned, err := reg.Learn(b)
+	if err != nil || !learned {
+		t.Fatalf("Learn = %v, %v", learned, err)
+	}
+	if !reflect.DeepEqual(res.Added, []string{"robot.Pose"}) || len(res.Changed) != 0 {
+		t.Errorf("Learn result = %+v, want only robot.Pose added", res)
+	}
+	if !reg.KnowsFingerprint(fp) {
+		t.Errorf("fingerprint not known after Learn")
+	}
+	if _, learned, _ := reg.Learn(b); learned {
+		t.Errorf("second Learn of the same set reported new")
+	}
+
+	// Learned sets survive a restart.
+	reg2, _ := registry.Open([]string{filepath.Join(dir, "base.desc")})
+	if err := reg2.EnableUploads(filepath.Join(dir, "uploads")); err != nil {
+		t.Fatalf("EnableUploads failed: %v", err)
+	}
+	if !reg2.KnowsFingerprint(fp) {
+		t.Errorf("fingerprint forgotten after restart")
+	}
+	if _, err := reg2.FormatJSON("robot.Pose", nil); err != nil {
+		t.Errorf("learned type not resolvable after restart: %v", err)
+	}
+
+	if _, err := reg2.RemoveSet("fp-" + fp); err != nil {
+		t.Fatalf("RemoveSet failed: %v", err)
+	}
+	if reg2.KnowsFingerprint(fp) {
+		t.Errorf("fingerprint still known after its set was removed")
+	}
+
+	if _, _, err := reg.Learn([]byte("not a descriptor set")); err == nil {
+		t.Errorf("Learn accepted garbage")
+	}
+}
+
+func TestLearn_Limits(t *testing.T) {
+	push := func(pkg string) []byte {
+		b, err := proto.Marshal(makeSet(pkg, map[string][]string{"M": {"x"}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	open := func(l registry.LearnLimits) *registry.Registry {
+		reg, _ := registry.Open(nil)
+		if err := reg.EnableUploads(filepath.Join(t.TempDir(), "uploads")); err != nil {
+			t.Fatalf("EnableUploads failed: %v", err)
+		}
+		reg.SetLearnLimits(l)
+		return reg
+	}
+
+	reg := open(registry.LearnLimits{MaxSets: 1})
+	if _, _, err := reg.Learn(push("a")); err != nil {
+		t.Fatalf("Learn(a) failed: %v", err)
+	}
+	if _, learned, err := reg.Learn(push("b")); !errors.Is(err, registry.ErrLearnLimit) || learned {
+		t.Errorf("Learn(b) past MaxSets = %v, %v, want ErrLearnLimit", learned, err)
+	}
+	if reg.KnowsFingerprint(descset.Fingerprint(push("b"))) {
+		t.Errorf("rejected set marked known")
+	}
+	if _, err := reg.RemoveSet("fp-" + descset.Fingerprint(push("a"))); err != nil {
+		t.Fatalf("RemoveSet failed: %v", err)
+	}
+	if _, _, err := reg.Learn(push("b")); err != nil {
+		t.Errorf("Learn(b) after removing a failed: %v", err)
+	}
+
+	reg = open(registry.LearnLimits{MaxBytes: 16})
+	if _, _, err := reg.Learn(push("a")); !errors.Is(err, registry.ErrLearnLimit) {
+		t.Errorf("Learn past MaxBytes error = %v, want ErrLearnLimit", err)
+	}
+
+	reg = open(registry.LearnLimits{Interval: time.Hour})
+	if _, _, err := reg.Learn(push("a")); err != nil {
+		t.Fatalf("Learn(a) failed: %v", err)
+	}
+	if _, _, err := reg.Learn(push("b")); !errors.Is(err, registry.ErrLearnRate) {
+		t.Errorf("Learn(b) within Interval error = %v, want ErrLearnRate", err)
+	}
+}
@@ -0,0 +1,241 @@
+/*******************************************************************************
+*  internal/registry/history.go
+*
+*  Descriptor version history. Every time a message type's descriptor changes,
+*  the new version is recorded with the time from which it is valid, so that
+*  historical payloads are decoded with the schema they were written with
+*  rather than the current one.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Version is one recorded descriptor version of a message type.
+type Version struct {
+	Type        string `json:"type"`
+	Digest      string `json:"digest"`        // hex SHA-256 of the message descriptor
+	ValidFromMs int64  `json:"valid_from_ms"` // 0 for the first known version
+	Descriptors []byte `json:"-"`             // FileDescriptorSet: the type's file and its imports
+}
+
+// VersionStore persists descriptor versions across restarts.
+type VersionStore interface {
+	SaveVersion(v Version) error
+	LoadVersions() ([]Version, error)
+}
+
+// history holds the recorded versions of every type, oldest first.
+type history struct {
+	mu       sync.RWMutex
+	store    VersionStore
+	versions map[string][]*version
+}
+
+type version struct {
+	Version
+
+	once sync.Once
+	desc protoreflect.MessageDescriptor
+	err  error
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// EnableHistory loads the versions recorded in store and starts recording a
+// new version whenever a reload changes a type. Types seen for the first time
+// get a version valid from the beginning of time.
+func (r *Registry) EnableHistory(store VersionStore) error {
+	stored, err := store.LoadVersions()
+	if err != nil {
+		return fmt.Errorf("load schema versions: %w", err)
+	}
+
+	h := &history{store: store, versions: make(map[string][]*version)}
+	for _, v := range stored {
+		h.versions[v.Type] = append(h.versions[v.Type], &version{Version: v})
+	}
+	for _, vs := range h.versions {
+		sort.SliceStable(vs, func(i, j int) bool { return vs[i].ValidFromMs < vs[j].ValidFromMs })
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history.Store(h)
+	r.recordVersions(r.files.Load(), r.digests, time.Now().UnixMilli())
+	return nil
+}
+
+// recordVersions records a version for every type in digests whose latest
+// recorded version differs. r.mu must be held.
+func (r *Registry) recordVersions(files *protoregistry.Files, digests map[string][32]byte, nowMs int64) {
+	h := r.history.Load()
+	if h == nil || files == nil {
+		return
+	}
+
+	names := make([]string, 0, len(digests))
+	for name := range digests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		d := digests[name]
+		digest := hex.EncodeToString(d[:])
+
+		h.mu.RLock()
+		vs := h.versions[name]
+		h.mu.RUnlock()
+
+		validFrom := int64(0)
+		if len(vs) > 0 {
+			if vs[len(vs)-1].Digest == digest {
+				continue
+			}
+			validFrom = nowMs
+		}
+
+		set, err := fileSetFor(files, name)
+		if err != nil {
+			log.Printf("schema history: %v", err)
+			continue
+		}
+		v := Version{Type: name, Digest: digest, ValidFromMs: validFrom, Descriptors: set}
+		if err := h.store.SaveVersion(v); err != nil {
+			log.Printf("schema history: save %s: %v", name, err)
+			continue
+		}
+
+		h.mu.Lock()
+		h.versions[name] = append(h.versions[name], &version{Version: v})
+		h.mu.Unlock()
+	}
+}
+
+// fileSetFor serializes the file declaring typeName together with all of
+// its transitive imports, imports first.
+func fileSetFor(files *protoregistry.Files, typeName string) ([]byte, error) {
+	d, err := files.FindDescriptorByName(protoreflect.FullName(typeName))
+	if err != nil {
+		return nil, fmt.Errorf("find descriptor %q: %w", typeName, err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(d.ParentFile())
+
+	return proto.MarshalOptions{Deterministic: true}.Marshal(&set)
+}
+
+// Versions returns the recorded versions of typeName, oldest first.
+func (r *Registry) Versions(typeName string) []Version {
+	h := r.history.Load()
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	out := make([]Version, 0, len(h.versions[typeName]))
+	for _, v := range h.versions[typeName] {
+		out = append(out, v.Version)
+	}
+	return out
+}
+
+// descriptorAt returns the descriptor of typeName valid at tsMs, or nil if
+// the current descriptor applies (or no history is known).
+func (r *Registry) descriptorAt(typeName string, tsMs int64) (protoreflect.MessageDescriptor, error) {
+	h := r.history.Load()
+	if h == nil {
+		return nil, nil
+	}
+
+	h.mu.RLock()
+	vs := h.versions[typeName]
+	h.mu.RUnlock()
+
+	i := sort.Search(len(vs), func(i int) bool { return vs[i].ValidFromMs > tsMs }) - 1
+	if i < 0 {
+		return nil, nil
+	}
+	v := vs[i]
+
+	// The latest version is the one currently loaded, if the type still is.
+	if i == len(vs)-1 {
+		if _, err := r.findMessage(typeName); err == nil {
+			return nil, nil
+		}
+	}
+
+	v.once.Do(func() { v.desc, v.err = v.build() })
+	return v.desc, v.err
+}
+
+func (v *version) build() (protoreflect.MessageDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(v.Descriptors, &set); err != nil {
+		return nil, fmt.Errorf("unmarshal %s version %s: %w", v.Type, v.Digest, err)
+	}
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.File))
+	order := make([]string, 0, len(set.File))
+	for _, fdp := range set.File {
+		protos[fdp.GetName()] = fdp
+		order = append(order, fdp.GetName())
+	}
+
+	var res ReloadResult
+	files := buildFiles(protos, order, protoregistry.GlobalFiles, &res)
+	d, err := files.FindDescriptorByName(protoreflect.FullName(v.Type))
+	if err != nil {
+		return nil, fmt.Errorf("build %s version %s: %v %v", v.Type, v.Digest, err, res.Errors)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("descriptor %q is not a message", v.Type)
+	}
+	return md, nil
+}
+
+// FormatJSONAt is FormatJSON using the version of typeName that was current
+// at event time tsMs (Unix milliseconds).
+func (r *Registry) FormatJSONAt(typeName string, payload []byte, tsMs int64) ([]byte, error) {
+	return r.RenderAt(typeName, payload, tsMs, RenderOptions{})
+}
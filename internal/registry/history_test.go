@@ -0,0 +1,86 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+// memVersions is an in-memory registry.VersionStore.
+type memVersions struct{ saved []registry.Version }
+
+func (m *memVersions) SaveVersion(v registry.Version) error {
+	m.saved = append(m.saved, v)
+	return nil
+}
+
+func (m *memVersions) LoadVersions() ([]registry.Version, error) {
+	return append([]registry.Version(nil), m.saved...), nil
+}
+
+func TestFormatJSONAt_UsesVersionCurrentAtEventTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.desc")
+	writeSet(t, path, "a", map[string][]string{"M": {"name"}})
+
+	store := &memVersions{}
+	reg, _ := registry.Open([]string{dir})
+	if err := reg.EnableHistory(store); err != nil {
+		t.Fatalf("EnableHistory failed: %v", err)
+	}
+
+	before := time.Now().UnixMilli()
+	time.Sleep(5 * time.Millisecond)
+
+	// Field 1 is renamed; old payloads must still decode with the old name.
+	writeSet(t, path, "a", map[string][]string{"M": {"label"}})
+	reg.Reload()
+
+	payload := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "pump-1")
+
+	decode := func(ts int64) map[string]any {
+		t.Helper()
+		b, err := reg.FormatJSONAt("a.M", payload, ts)
+		if err != nil {
+			t.Fatalf("FormatJSONAt(%d) failed: %v", ts, err)
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(b, &obj); err != nil {
+			t.Fatalf("json.Unmarshal failed: %v", err)
+		}
+		return obj
+	}
+
+	if got := decode(before); !reflect.DeepEqual(got, map[string]any{"name": "pump-1"}) {
+		t.Errorf("old event decoded as %v, want name", got)
+	}
+	if got := decode(time.Now().Add(time.Minute).UnixMilli()); !reflect.DeepEqual(got, map[string]any{"label": "pump-1"}) {
+		t.Errorf("new event decoded as %v, want label", got)
+	}
+
+	vs := reg.Versions("a.M")
+	if len(vs) != 2 || vs[0].ValidFromMs != 0 || vs[1].ValidFromMs < before {
+		t.Errorf("versions = %+v", vs)
+	}
+
+	// A fresh registry over the same store sees the history, including after
+	// the type is gone from the sources.
+	writeSet(t, path, "b", nil)
+	reg2, _ := registry.Open([]string{dir})
+	if err := reg2.EnableHistory(store); err != nil {
+		t.Fatalf("EnableHistory failed: %v", err)
+	}
+	if len(store.saved) != 2 {
+		t.Errorf("restart recorded %d versions, want 2 (no new ones)", len(store.saved))
+	}
+	b, err := reg2.FormatJSONAt("a.M", payload, before)
+	if err != nil || string(b) == "" {
+		t.Errorf("FormatJSONAt on removed type = %s, %v", b, err)
+	}
+}
@@ -0,0 +1,282 @@
+/*******************************************************************************
+*  internal/registry/number.go
+*
+*  Numeric field extraction. A field path such as "value", "reading.value",
+*  "readings[2].value" or "gauges[cpu]" addresses one numeric value in a
+*  decoded payload, which is how telemetry payloads are turned into time
+*  series.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// pathStep is one segment of a field path: a field, optionally indexed
+// (lists) or keyed (maps).
+type pathStep struct {
+	field string
+	index string // list index or map key; "" if none
+	keyed bool
+}
+
+/*******************************************************************************
+*  ERRORS
+*******************************************************************************/
+
+var (
+	// ErrNoValue is returned when a payload does not populate the field
+	// addressed by a path (an unset message or optional field, a list index
+	// out of range, a missing map key or a redacted field).
+	ErrNoValue = errors.New("no value at field path")
+
+	// ErrNotNumeric is returned for paths that do not end at a numeric field.
+	ErrNotNumeric = errors.New("field is not numeric")
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// CheckNumericPath reports whether path addresses a numeric field of
+// typeName: an integer, floating-point or enum field, or a wrapper type
+// such as google.protobuf.DoubleValue.
+func (r *Registry) CheckNumericPath(typeName, path string) error {
+	md, err := r.findMessage(typeName)
+	if err != nil {
+		return err
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	for i, s := range steps {
+		fd := fieldByName(md, s.field)
+		if fd == nil {
+			return fmt.Errorf("%s has no field %q", md.FullName(), s.field)
+		}
+		if s.keyed && !fd.IsList() && !fd.IsMap() {
+			return fmt.Errorf("field %q is not a list or map", s.field)
+		}
+		if !s.keyed && (fd.IsList() || fd.IsMap()) {
+			return fmt.Errorf("field %q needs an index or key", s.field)
+		}
+		if fd.IsMap() {
+			fd = fd.MapValue()
+		}
+
+		if i == len(steps)-1 {
+			if numericKind(fd) || isWrapper(fd) {
+				return nil
+			}
+			return fmt.Errorf("%w: %s is %s", ErrNotNumeric, path, fd.Kind())
+		}
+		if fd.Message() == nil {
+			return fmt.Errorf("field %q is not a message", s.field)
+		}
+		md = fd.Message()
+	}
+	return nil
+}
+
+// Number decodes payload as typeName (as of event time tsMs), redacts it and
+// returns the numeric value at path. Unset fields with presence (messages,
+// optional scalars) have no value; other unpopulated scalars read as zero.
+func (r *Registry) Number(typeName string, payload []byte, tsMs int64, path string) (float64, error) {
+	if r == nil {
+		return 0, fmt.Errorf("registry is nil")
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	md, err := r.descriptorAt(typeName, tsMs)
+	if err != nil {
+		return 0, err
+	}
+	if md == nil {
+		if md, err = r.findMessage(typeName); err != nil {
+			return 0, err
+		}
+	}
+	msg, err := decodeAs(md, payload)
+	if err != nil {
+		return 0, err
+	}
+
+	m := protoreflect.Message(msg)
+	for i, s := range steps {
+		fd := fieldByName(m.Descriptor(), s.field)
+		if fd == nil {
+			return 0, fmt.Errorf("%s has no field %q", m.Descriptor().FullName(), s.field)
+		}
+		if r.redactor != nil && r.redactor.redactsField(fd) {
+			return 0, ErrNoValue
+		}
+
+		v := m.Get(fd)
+		switch {
+		case fd.IsList():
+			if !s.keyed {
+				return 0, fmt.Errorf("field %q needs an index", s.field)
+			}
+			idx, err := strconv.Atoi(s.index)
+			if err != nil || idx < 0 {
+				return 0, fmt.Errorf("invalid index %q for field %q", s.index, s.field)
+			}
+			if idx >= v.List().Len() {
+				return 0, ErrNoValue
+			}
+			v = v.List().Get(idx)
+		case fd.IsMap():
+			if !s.keyed {
+				return 0, fmt.Errorf("field %q needs a key", s.field)
+			}
+			key, err := mapKey(fd.MapKey(), s.index)
+			if err != nil {
+				return 0, err
+			}
+			if !v.Map().Has(key) {
+				return 0, ErrNoValue
+			}
+			v = v.Map().Get(key)
+			fd = fd.MapValue()
+		case s.keyed:
+			return 0, fmt.Errorf("field %q is not a list or map", s.field)
+		case fd.HasPresence() && !m.Has(fd):
+			return 0, ErrNoValue
+		}
+
+		if i == len(steps)-1 {
+			return number(fd, v)
+		}
+		if fd.Message() == nil {
+			return 0, fmt.Errorf("field %q is not a message", s.field)
+		}
+		m = v.Message()
+	}
+	return 0, fmt.Errorf("empty field path")
+}
+
+// parsePath splits "a.b[2].c" into steps.
+func parsePath(path string) ([]pathStep, error) {
+	if path == "" {
+		return nil, fmt.Errorf("empty field path")
+	}
+	var steps []pathStep
+	for _, seg := range strings.Split(path, ".") {
+		s := pathStep{field: seg}
+		if open := strings.IndexByte(seg, '['); open >= 0 {
+			if !strings.HasSuffix(seg, "]") || open == 0 {
+				return nil, fmt.Errorf("invalid field path %q", path)
+			}
+			s = pathStep{field: seg[:open], index: seg[open+1 : len(seg)-1], keyed: true}
+		}
+		if s.field == "" {
+			return nil, fmt.Errorf("invalid field path %q", path)
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// fieldByName finds a field by proto name or JSON name.
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return md.Fields().ByJSONName(name)
+}
+
+func mapKey(fd protoreflect.FieldDescriptor, s string) (protoreflect.MapKey, error) {
+	var (
+		v   protoreflect.Value
+		err error
+	)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(s)
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(s, 10, 64)
+		v = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 32)
+		v = protoreflect.ValueOfUint32(uint32(n))
+	default:
+		var n uint64
+		n, err = strconv.ParseUint(s, 10, 64)
+		v = protoreflect.ValueOfUint64(n)
+	}
+	if err != nil {
+		return protoreflect.MapKey{}, fmt.Errorf("invalid map key %q: %w", s, err)
+	}
+	return v.MapKey(), nil
+}
+
+func numericKind(fd protoreflect.FieldDescriptor) bool {
+	switch fd.Kind() {
+	case protoreflect.BoolKind, protoreflect.StringKind, protoreflect.BytesKind,
+		protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return true
+}
+
+// isWrapper reports whether fd is a numeric google.protobuf wrapper message.
+func isWrapper(fd protoreflect.FieldDescriptor) bool {
+	if fd.Message() == nil || fd.Message().ParentFile().Package() != "google.protobuf" {
+		return false
+	}
+	switch fd.Message().Name() {
+	case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value", "Int32Value", "UInt32Value":
+		return true
+	}
+	return false
+}
+
+// number converts the value of fd to a float64.
+func number(fd protoreflect.FieldDescriptor, v protoreflect.Value) (float64, error) {
+	if isWrapper(fd) {
+		inner := fd.Message().Fields().ByName("value")
+		return number(inner, v.Message().Get(inner))
+	}
+	switch fd.Kind() {
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float(), nil
+	case protoreflect.Int32Kind, protoreflect.Int64Kind, protoreflect.Sint32Kind,
+		protoreflect.Sint64Kind, protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind:
+		return float64(v.Int()), nil
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind, protoreflect.Fixed32Kind,
+		protoreflect.Fixed64Kind:
+		return float64(v.Uint()), nil
+	case protoreflect.EnumKind:
+		return float64(v.Enum()), nil
+	}
+	return 0, fmt.Errorf("%w: %s is %s", ErrNotNumeric, fd.FullName(), fd.Kind())
+}
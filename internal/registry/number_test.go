@@ -0,0 +1,39 @@
+package registry_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+func TestNumber(t *testing.T) {
+	reg := openTree(t)
+	payload := treePayload(t, reg)
+
+	for path, want := range map[string]float64{
+		"kind":             1,
+		"counts[b]":        2,
+		"children[0].kind": 0, // unpopulated, no presence
+	} {
+		got, err := reg.Number("tree.Node", payload, 0, path)
+		if err != nil || got != want {
+			t.Errorf("Number(%s) = %v, %v; want %v", path, got, err, want)
+		}
+	}
+
+	for _, path := range []string{"children[1].kind", "counts[z]", "weight", "children[0].counts[a]"} {
+		if _, err := reg.Number("tree.Node", payload, 0, path); !errors.Is(err, registry.ErrNoValue) {
+			t.Errorf("Number(%s) error = %v, want ErrNoValue", path, err)
+		}
+	}
+
+	if err := reg.CheckNumericPath("tree.Node", "children[0].weight"); err != nil {
+		t.Errorf("CheckNumericPath(children[0].weight) = %v", err)
+	}
+	for _, path := range []string{"name", "children", "counts", "kind[0]", "nope", "children[0].name", "a..b"} {
+		if err := reg.CheckNumericPath("tree.Node", path); err == nil {
+			t.Errorf("CheckNumericPath(%s) succeeded, want error", path)
+		}
+	}
+}
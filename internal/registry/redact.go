@@ -0,0 +1,285 @@
+/*******************************************************************************
+*  internal/registry/redact.go
+*
+*  Field-level redaction of decoded payloads. Fields are redacted when named
+*  explicitly by fully qualified name or annotated with the custom option
+*  (protolog.sensitive) = true, and string values anywhere in a payload (and in
+*  envelope summaries) are scrubbed of matches of configured patterns.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"fmt"
+	"regexp"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// RedactedValue replaces redacted strings and string matches.
+const RedactedValue = "[REDACTED]"
+
+// SensitiveOption is the full name of the field option marking a field as
+// sensitive; see proto/protolog/options.proto.
+const SensitiveOption protoreflect.FullName = "protolog.sensitive"
+
+// sensitiveOptionNumber is the extension number of SensitiveOption, used
+// when the option is present in a descriptor only as an unknown field.
+const sensitiveOptionNumber protowire.Number = 72041
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// RedactionRules configures a Redactor.
+type RedactionRules struct {
+	// Fields are fully qualified field names, e.g. "demo.LoginEvent.password".
+	Fields []string
+
+	// Patterns are applied to every string value; matches are replaced by
+	// RedactedValue.
+	Patterns []string
+
+	// IgnoreSensitiveOption disables redaction of fields annotated with
+	// (protolog.sensitive) = true.
+	IgnoreSensitiveOption bool
+}
+
+// Redactor applies RedactionRules. A nil *Redactor redacts nothing.
+type Redactor struct {
+	fields    map[protoreflect.FullName]struct{}
+	patterns  []*regexp.Regexp
+	sensitive bool
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// NewRedactor compiles rules. Note that a nil *Redactor does not honour the
+// sensitive option either; NewRedactor(RedactionRules{}) is the default.
+func NewRedactor(rules RedactionRules) (*Redactor, error) {
+	rd := &Redactor{
+		fields:    make(map[protoreflect.FullName]struct{}, len(rules.Fields)),
+		sensitive: !rules.IgnoreSensitiveOption,
+	}
+	for _, f := range rules.Fields {
+		rd.fields[protoreflect.FullName(f)] = struct{}{}
+	}
+	for _, p := range rules.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("redaction pattern %q: %w", p, err)
+		}
+		rd.patterns = append(rd.patterns, re)
+	}
+	return rd, nil
+}
+
+// String scrubs pattern matches from s.
+func (rd *Redactor) String(s string) string {
+	if rd == nil {
+		return s
+	}
+	for _, re := range rd.patterns {
+		s = re.ReplaceAllString(s, RedactedValue)
+	}
+	return s
+}
+
+// Message redacts msg in place. The contents of google.protobuf.Any values
+// are left alone; see MessageWithTypes.
+func (rd *Redactor) Message(msg protoreflect.Message) {
+	rd.MessageWithTypes(msg, nil)
+}
+
+// MessageWithTypes redacts msg in place, including inside google.protobuf.Any
+// values (msg itself among them) whose type can be resolved by types.
+func (rd *Redactor) MessageWithTypes(msg protoreflect.Message, types protoregistry.MessageTypeResolver) {
+	if rd == nil {
+		return
+	}
+	if msg.Descriptor().FullName() == anyFullName && types != nil {
+		rd.anyValue(msg, types)
+		return
+	}
+
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if rd.redactsField(fd) {
+			rd.redactField(msg, fd, v)
+			return true
+		}
+
+		switch {
+		case fd.IsMap():
+			m := v.Map()
+			m.Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				if nv, ok := rd.value(fd.MapValue(), mv, types); ok {
+					m.Set(k, nv)
+				}
+				return true
+			})
+		case fd.IsList():
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				if nv, ok := rd.value(fd, l.Get(i), types); ok {
+					l.Set(i, nv)
+				}
+			}
+		default:
+			if nv, ok := rd.value(fd, v, types); ok {
+				msg.Set(fd, nv)
+			}
+		}
+		return true
+	})
+}
+
+// value scrubs a single (non-redacted) value, reporting whether it changed.
+// Nested messages are redacted in place.
+func (rd *Redactor) value(fd protoreflect.FieldDescriptor, v protoreflect.Value,
+	types protoregistry.MessageTypeResolver) (protoreflect.Value, bool) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if s := rd.String(v.String()); s != v.String() {
+			return protoreflect.ValueOfString(s), true
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		rd.MessageWithTypes(v.Message(), types)
+	}
+	return v, false
+}
+
+// anyValue redacts the message packed in the google.protobuf.Any m, if its
+// type resolves. Unresolvable values are left as they are.
+func (rd *Redactor) anyValue(m protoreflect.Message, types protoregistry.MessageTypeResolver) {
+	fields := m.Descriptor().Fields()
+	urlField, valueField := fields.ByName("type_url"), fields.ByName("value")
+	if urlField == nil || valueField == nil {
+		return
+	}
+
+	mt, err := types.FindMessageByURL(m.Get(urlField).String())
+	if err != nil {
+		return
+	}
+	inner := mt.New()
+	if err := proto.Unmarshal(m.Get(valueField).Bytes(), inner.Interface()); err != nil {
+		return
+	}
+	rd.MessageWithTypes(inner, types)
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(inner.Interface())
+	if err != nil {
+		return
+	}
+	m.Set(valueField, protoreflect.ValueOfBytes(b))
+}
+
+// redactField replaces a redacted field's value: strings become
+// RedactedValue, everything else is cleared.
+func (rd *Redactor) redactField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	if fd.Kind() != protoreflect.StringKind || fd.IsMap() {
+		msg.Clear(fd)
+		return
+	}
+	if fd.IsList() {
+		l := v.List()
+		for i := 0; i < l.Len(); i++ {
+			l.Set(i, protoreflect.ValueOfString(RedactedValue))
+		}
+		return
+	}
+	msg.Set(fd, protoreflect.ValueOfString(RedactedValue))
+}
+
+func (rd *Redactor) redactsField(fd protoreflect.FieldDescriptor) bool {
+	if _, ok := rd.fields[fd.FullName()]; ok {
+		return true
+	}
+	return rd.sensitive && IsSensitive(fd)
+}
+
+// IsSensitive reports whether fd carries (protolog.sensitive) = true. The
+// option is found whether or not its Go extension type is linked in.
+func IsSensitive(fd protoreflect.FieldDescriptor) bool {
+	opts := fd.Options()
+	if opts == nil {
+		return false
+	}
+	m := opts.ProtoReflect()
+	if !m.IsValid() {
+		return false
+	}
+
+	found := false
+	m.Range(func(xd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if xd.IsExtension() && xd.FullName() == SensitiveOption {
+			found = v.Bool()
+			return false
+		}
+		return true
+	})
+	if found {
+		return true
+	}
+
+	b := m.GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		if num == sensitiveOptionNumber && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return false
+			}
+			found = v != 0
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return found
+}
+
+// SetRedactor installs rd; FormatJSON output is redacted from then on.
+func (r *Registry) SetRedactor(rd *Redactor) {
+	if r != nil {
+		r.redactor = rd
+	}
+}
+
+// Redact decodes payload as typeName, applies the registry's redactor and
+// re-encodes it. It is used to scrub payloads before they are stored. An
+// unknown typeName yields an error wrapping ErrUnknownType; any other error
+// means the payload could not be scrubbed and must not be stored as it is.
+func (r *Registry) Redact(typeName string, payload []byte) ([]byte, error) {
+	md, err := r.findMessage(typeName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, typeName)
+	}
+	msg, err := decodeAs(md, payload)
+	if err != nil {
+		return nil, err
+	}
+	r.redactor.MessageWithTypes(msg.ProtoReflect(), r.Resolver())
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
@@ -0,0 +1,198 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/internal/registry"
+	"github.com/Espeer5/protolog/pkg/logproto/demo"
+)
+
+func loadRegistry(t *testing.T) *registry.Registry {
+	t.Helper()
+
+	descPath := descriptorPath()
+	if _, err := os.Stat(descPath); os.IsNotExist(err) {
+		t.Skipf("schema descriptor %q not found; run `make proto` first", descPath)
+	}
+	reg, err := registry.NewFromFile(descPath)
+	if err != nil {
+		t.Fatalf("NewFromFile(%q) failed: %v", descPath, err)
+	}
+	return reg
+}
+
+func TestFormatJSON_Redaction(t *testing.T) {
+	reg := loadRegistry(t)
+
+	rd, err := registry.NewRedactor(registry.RedactionRules{
+		Fields:   []string{"demo.LoginEvent.client_ip"},
+		Patterns: []string{`tok_[A-Za-z0-9]+`},
+	})
+	if err != nil {
+		t.Fatalf("NewRedactor failed: %v", err)
+	}
+	reg.SetRedactor(rd)
+
+	payload, err := proto.Marshal(&demo.LoginEvent{
+		User:     "alice (tok_abc123)",
+		Password: "hunter2",
+		Session:  "s-1",
+		ClientIp: "10.0.0.7",
+		Success:  true,
+	})
+	if err != nil {
+		t.Fatalf("proto.Marshal failed: %v", err)
+	}
+
+	b, err := reg.FormatJSON("demo.LoginEvent", payload)
+	if err != nil {
+		t.Fatalf("FormatJSON failed: %v", err)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(b, &obj); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+
+	want := map[string]any{
+		"user":      "alice ([REDACTED])", // pattern
+		"password":  "[REDACTED]",         // (protolog.sensitive) option
+		"session":   "[REDACTED]",         // (protolog.sensitive) option
+		"client_ip": "[REDACTED]",         // by field name
+		"success":   true,
+	}
+	for k, v := range want {
+		if obj[k] != v {
+			t.Errorf("%s = %v, want %v", k, obj[k], v)
+		}
+	}
+
+	// Scrubbing the stored bytes yields the same redacted message.
+	scrubbed, err := reg.Redact("demo.LoginEvent", payload)
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	var got demo.LoginEvent
+	if err := proto.Unmarshal(scrubbed, &got); err != nil {
+		t.Fatalf("proto.Unmarshal failed: %v", err)
+	}
+	if got.Password != registry.RedactedValue || got.User != "alice ([REDACTED])" || !got.Success {
+		t.Errorf("scrubbed payload = %+v", &got)
+	}
+}
+
+func TestFormatJSON_IgnoreSensitiveOption(t *testing.T) {
+	reg := loadRegistry(t)
+
+	rd, err := registry.NewRedactor(registry.RedactionRules{IgnoreSensitiveOption: true})
+	if err != nil {
+		t.Fatalf("NewRedactor failed: %v", err)
+	}
+	reg.SetRedactor(rd)
+
+	payload, _ := proto.Marshal(&demo.LoginEvent{Password: "hunter2"})
+	b, err := reg.FormatJSON("demo.LoginEvent", payload)
+	if err != nil {
+		t.Fatalf("FormatJSON failed: %v", err)
+	}
+	var obj map[string]any
+	_ = json.Unmarshal(b, &obj)
+	if obj["password"] != "hunter2" {
+		t.Errorf("password = %v, want unredacted", obj["password"])
+	}
+}
+
+func TestNewRedactor_InvalidPattern(t *testing.T) {
+	if _, err := registry.NewRedactor(registry.RedactionRules{Patterns: []string{"("}}); err == nil {
+		t.Fatal("NewRedactor succeeded with invalid pattern")
+	}
+}
+
+func TestIsSensitive_UnlinkedOption(t *testing.T) {
+	// Options as they appear when the protolog extension is not linked in:
+	// field 72041, varint 1, kept as unknown bytes.
+	opts := &descriptorpb.FieldOptions{}
+	opts.ProtoReflect().SetUnknown(protowire.AppendVarint(
+		protowire.AppendTag(nil, 72041, protowire.VarintType), 1))
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("t.proto"),
+		Package: proto.String("t"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("M"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("secret"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Options: opts},
+				{Name: proto.String("plain"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, nil)
+	if err != nil {
+		t.Fatalf("protodesc.NewFile failed: %v", err)
+	}
+
+	fields := fd.Messages().Get(0).Fields()
+	if !registry.IsSensitive(fields.ByName("secret")) {
+		t.Errorf("secret not detected as sensitive")
+	}
+	if registry.IsSensitive(fields.ByName("plain")) {
+		t.Errorf("plain detected as sensitive")
+	}
+}
+
+func TestRenderPayload_UndecodableKnownTypeIsBlanked(t *testing.T) {
+	reg := loadRegistry(t)
+
+	payload, err := proto.Marshal(&demo.LoginEvent{User: "alice", Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("proto.Marshal failed: %v", err)
+	}
+	truncated := payload[:len(payload)-2]
+
+	r := reg.RenderPayload("demo.LoginEvent", truncated, 0, registry.RenderOptions{})
+	if !r.Heuristic {
+		t.Fatalf("RenderPayload(truncated) = %s, want a heuristic rendering", r.Data)
+	}
+	// hex of "hunt", in case the rest is shown as trailing bytes
+	for _, leak := range []string{"alice", "hunt", "68756e74"} {
+		if strings.Contains(string(r.Data), leak) {
+			t.Errorf("RenderPayload(truncated) leaks %q: %s", leak, r.Data)
+		}
+	}
+
+	// Without a known type, strings stay readable.
+	if r := reg.RenderPayload("demo.Missing", truncated, 0, registry.RenderOptions{}); !strings.Contains(string(r.Data), "alice") {
+		t.Errorf("RenderPayload(unknown type) = %s, want the heuristic strings", r.Data)
+	}
+}
+
+func TestRedact_UndecodableKnownTypeFails(t *testing.T) {
+	reg := loadRegistry(t)
+
+	payload, err := proto.Marshal(&demo.LoginEvent{User: "alice", Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("proto.Marshal failed: %v", err)
+	}
+	truncated := payload[:len(payload)-2]
+
+	b, err := reg.Redact("demo.LoginEvent", truncated)
+	if err == nil || errors.Is(err, registry.ErrUnknownType) {
+		t.Fatalf("Redact(truncated) error = %v, want a decode error", err)
+	}
+	if b != nil {
+		t.Errorf("Redact(truncated) = %x, want nil", b)
+	}
+
+	if _, err := reg.Redact("demo.Missing", truncated); !errors.Is(err, registry.ErrUnknownType) {
+		t.Errorf("Redact(unknown type) error = %v, want ErrUnknownType", err)
+	}
+}
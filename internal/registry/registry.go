@@ -9,12 +9,20 @@
 package registry
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"google.golang.org/protobuf/encoding/protojson"
+	"github.com/fsnotify/fsnotify"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -27,8 +35,61 @@ import (
 *  TYPES
 *******************************************************************************/
 
+// Registry resolves message types by full name. The set of files is swapped
+// atomically on reload, so lookups never block and always see a consistent
+// snapshot.
 type Registry struct {
+	sources   []string     // descriptor set files and directories
+	protos    ProtoSources // .proto sources compiled on reload; see compile.go
+	files     atomic.Pointer[protoregistry.Files]
+	types     atomic.Pointer[protoregistry.Types] // built from files; see types.go
+	gen       atomic.Uint64                       // incremented by every reload
+	redactor  *Redactor
+	summaries *SummaryTemplates // see summary.go
+
+	mu      sync.Mutex          // serializes reloads and uploads
+	compat  CompatPolicy        // guarded by mu; see compat.go
+	digests map[string][32]byte // message full name -> descriptor digest
+	origins map[string]string   // file name -> descriptor set it came from
+
+	history atomic.Pointer[history] // descriptor versions; see history.go
+
+	uploadDir string     // where uploaded descriptor sets are stored; see uploads.go
+	uploadMu  sync.Mutex // serializes validate-and-store of uploads
+
+	fpMu      sync.Mutex      // guards the fields below; see fingerprint.go
+	known     map[string]bool // fingerprints of descriptor sets learned
+	missing   map[string]bool // unknown fingerprints already reported
+	limits    *LearnLimits    // nil means DefaultLearnLimits
+	lastLearn time.Time       // when a learned set was last stored
+
+	watcher *fsnotify.Watcher
+}
+
+// ReloadResult summarizes a (re)load of the registry's sources.
+type ReloadResult struct {
+	Files   int      `json:"files"`
+	Types   int      `json:"types"`
+	Added   []string `json:"added,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Compat  []Change `json:"compat,omitempty"` // see compat.go
+	Errors  []string `json:"errors,omitempty"`
+
+	// Rejected lists the files kept at their previous version because
+	// their update has breaking changes (CompatReject).
+	Rejected []string `json:"rejected,omitempty"`
+
+	// CompileErrors locates the problems with .proto sources (also
+	// listed in Errors); see compile.go.
+	CompileErrors []CompileError `json:"compile_errors,omitempty"`
+}
+
+// fallbackResolver resolves against files first and then against base,
+// by default the files linked into the collector (well-known types etc.).
+type fallbackResolver struct {
 	files *protoregistry.Files
+	base  protodesc.Resolver
 }
 
 /*******************************************************************************
@@ -41,38 +102,49 @@ func NewFromFile(path string) (*Registry, error) {
 	return NewFromFiles([]string{path})
 }
 
-// NewFromFiles loads and merges multiple FileDescriptorSet files into one registry.
+// NewFromFiles loads and merges multiple FileDescriptorSet files into one
+// registry. Any file that fails to load is an error; see Open for a
+// registry that tolerates partial failures.
 func NewFromFiles(paths []string) (*Registry, error) {
 	if len(paths) == 0 {
 		return nil, fmt.Errorf("no descriptor paths provided")
 	}
 
-	var combined descriptorpb.FileDescriptorSet
-
-	for _, path := range paths {
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("read descriptor set %q: %w", path, err)
-		}
-
-		var fds descriptorpb.FileDescriptorSet
-		if err := proto.Unmarshal(data, &fds); err != nil {
-			return nil, fmt.Errorf("unmarshal descriptor set %q: %w", path, err)
-		}
-
-		combined.File = append(combined.File, fds.File...)
+	r := &Registry{sources: paths}
+	if res := r.Reload(); len(res.Errors) > 0 {
+		return nil, errors.New(strings.Join(res.Errors, "; "))
 	}
+	return r, nil
+}
 
-	files, err := protodesc.NewFiles(&combined)
+// NewFromDir loads all *.desc files in the given directory into a single registry.
+func NewFromDir(dir string) (*Registry, error) {
+	paths, err := descFiles(dir)
 	if err != nil {
-		return nil, fmt.Errorf("build files registry: %w", err)
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .desc files found in %q", dir)
 	}
 
-	return &Registry{files: files}, nil
+	return NewFromFiles([]string{dir})
 }
 
-// NewFromDir loads all *.desc files in the given directory into a single registry.
-func NewFromDir(dir string) (*Registry, error) {
+// Open creates a registry over the given descriptor set files and
+// directories and loads whatever it can. It never returns nil: files that
+// fail to load are reported in the result and skipped, and missing sources
+// may appear later (see Reload and Watch).
+func Open(sources []string) (*Registry, ReloadResult) {
+	r := &Registry{sources: sources}
+	return r, r.Reload()
+}
+
+// Sources returns the files and directories the registry loads from.
+func (r *Registry) Sources() []string {
+	return append([]string(nil), r.sources...)
+}
+
+func descFiles(dir string) ([]string, error) {
 	var paths []string
 
 	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
@@ -90,16 +162,300 @@ func NewFromDir(dir string) (*Registry, error) {
 	if err != nil {
 		return nil, fmt.Errorf("walk dir %q: %w", dir, err)
 	}
+	return paths, nil
+}
 
-	if len(paths) == 0 {
-		return nil, fmt.Errorf("no .desc files found in %q", dir)
+// Reload re-reads every source and atomically swaps in the resulting files.
+// Descriptor sets or files that fail to load (unreadable, malformed,
+// conflicting or with unresolvable imports) are skipped and reported; the
+// rest are still loaded. Only files that changed since the last reload are
+// checked for compatibility; under CompatReject, those with breaking changes
+// keep their previous version while the other updates go through.
+func (r *Registry) Reload() ReloadResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var res ReloadResult
+	protos, order, origins := r.readSources(&res)
+	order = r.readProtoSources(protos, order, origins, &res)
+	nerr := len(res.Errors)
+	files := buildFiles(protos, order, protoregistry.GlobalFiles, &res)
+
+	if prev := r.files.Load(); prev != nil && r.compatPolicy() != CompatOff {
+		res.Compat = CompareFiles(prev, files, changedFiles(prev, files))
+		if r.compatPolicy() == CompatReject && HasBreaking(res.Compat) {
+			var breaking []Change
+			for _, c := range res.Compat {
+				if c.Severity == Breaking {
+					breaking = append(breaking, c)
+				}
+			}
+			for _, path := range breakingFiles(prev, breaking) {
+				fd, _ := prev.FindFileByPath(path)
+				if _, ok := protos[path]; !ok {
+					order = append(order, path)
+				}
+				protos[path] = protodesc.ToFileDescriptorProto(fd)
+				origins[path] = r.origins[path]
+				res.Rejected = append(res.Rejected, path)
+			}
+			res.Errors = append(res.Errors[:nerr], fmt.Sprintf("%v; keeping the previous version of %s",
+				&IncompatibleError{Changes: breaking}, strings.Join(res.Rejected, ", ")))
+			files = buildFiles(protos, order, protoregistry.GlobalFiles, &res)
+		}
+	}
+
+	digests := typeDigests(files)
+	for name, d := range digests {
+		old, ok := r.digests[name]
+		switch {
+		case !ok:
+			res.Added = append(res.Added, name)
+		case old != d:
+			res.Changed = append(res.Changed, name)
+		}
+	}
+	for name := range r.digests {
+		if _, ok := digests[name]; !ok {
+			res.Removed = append(res.Removed, name)
+		}
+	}
+	sort.Strings(res.Added)
+	sort.Strings(res.Changed)
+	sort.Strings(res.Removed)
+	res.Files = files.NumFiles()
+	res.Types = len(digests)
+
+	r.digests = digests
+	r.origins = origins
+	r.files.Store(files)
+	r.types.Store(buildTypes(files, &res))
+	r.gen.Add(1)
+	r.recordVersions(files, digests, time.Now().UnixMilli())
+
+	logReload(res)
+	return res
+}
+
+// changedFiles returns the paths of the files of prev that files no longer
+// contains or contains in a different version.
+func changedFiles(prev, files *protoregistry.Files) map[string]bool {
+	changed := make(map[string]bool)
+	prev.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		nfd, err := files.FindFileByPath(fd.Path())
+		if err != nil || !equalIgnoringSourceInfo(protodesc.ToFileDescriptorProto(fd), protodesc.ToFileDescriptorProto(nfd)) {
+			changed[fd.Path()] = true
+		}
+		return true
+	})
+	return changed
+}
+
+// breakingFiles returns the sorted paths of the files of prev declaring the
+// types changed by changes.
+func breakingFiles(prev *protoregistry.Files, changes []Change) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, c := range changes {
+		d, err := prev.FindDescriptorByName(protoreflect.FullName(c.Type))
+		if err != nil || seen[d.ParentFile().Path()] {
+			continue
+		}
+		seen[d.ParentFile().Path()] = true
+		paths = append(paths, d.ParentFile().Path())
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func logReload(res ReloadResult) {
+	log.Printf("schema registry: %d file(s), %d message type(s) loaded", res.Files, res.Types)
+	if len(res.Added) > 0 {
+		log.Printf("schema registry: added %s", strings.Join(res.Added, ", "))
+	}
+	if len(res.Changed) > 0 {
+		log.Printf("schema registry: changed %s", strings.Join(res.Changed, ", "))
+	}
+	if len(res.Removed) > 0 {
+		log.Printf("schema registry: removed %s", strings.Join(res.Removed, ", "))
+	}
+	for _, c := range res.Compat {
+		log.Printf("schema registry: %s change: %s", c.Severity, c)
 	}
+	for _, e := range res.Errors {
+		log.Printf("schema registry: %s", e)
+	}
+}
+
+// readSources reads every descriptor set, returning file descriptors by
+// name in load order and the set each came from. The first definition of a
+// file name wins.
+func (r *Registry) readSources(res *ReloadResult) (map[string]*descriptorpb.FileDescriptorProto, []string, map[string]string) {
+	var paths []string
+	for _, src := range r.sources {
+		info, err := os.Stat(src)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("read descriptor set %q: %v", src, err))
+			continue
+		}
+		if !info.IsDir() {
+			paths = append(paths, src)
+			continue
+		}
+		found, err := descFiles(src)
+		if err != nil {
+			res.Errors = append(res.Errors, err.Error())
+		}
+		paths = append(paths, found...)
+	}
+
+	protos := make(map[string]*descriptorpb.FileDescriptorProto)
+	origins := make(map[string]string)
+	var order []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("read descriptor set %q: %v", path, err))
+			continue
+		}
 
-	return NewFromFiles(paths)
+		var fds descriptorpb.FileDescriptorSet
+		if err := proto.Unmarshal(data, &fds); err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("unmarshal descriptor set %q: %v", path, err))
+			continue
+		}
+
+		for _, fdp := range fds.File {
+			name := fdp.GetName()
+			if prev, ok := protos[name]; ok {
+				// Imports are commonly included in several sets.
+				if !equalIgnoringSourceInfo(prev, fdp) {
+					res.Errors = append(res.Errors, fmt.Sprintf(
+						"file %q in %q differs from an earlier definition; keeping the first", name, path))
+				}
+				continue
+			}
+			protos[name] = fdp
+			origins[name] = path
+			order = append(order, name)
+		}
+	}
+	return protos, order, origins
+}
+
+// buildFiles links the file descriptors in dependency order, resolving
+// imports missing from protos against base. A file that fails to link is
+// skipped along with every file importing it.
+func buildFiles(protos map[string]*descriptorpb.FileDescriptorProto, order []string,
+	base protodesc.Resolver, res *ReloadResult) *protoregistry.Files {
+	files := new(protoregistry.Files)
+	resolver := fallbackResolver{files: files, base: base}
+
+	const (
+		visiting = iota + 1
+		done
+		failed
+	)
+	state := make(map[string]int, len(protos))
+
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			res.Errors = append(res.Errors, fmt.Sprintf("build files registry: import cycle through %q", name))
+			return false
+		case done:
+			return true
+		case failed:
+			return false
+		}
+
+		fdp, ok := protos[name]
+		if !ok {
+			// Not in any set; may still resolve from the linked-in files.
+			return true
+		}
+
+		state[name] = visiting
+		for _, dep := range fdp.GetDependency() {
+			if !visit(dep) {
+				state[name] = failed
+				res.Errors = append(res.Errors, fmt.Sprintf("build files registry: skipping %q: import %q failed", name, dep))
+				return false
+			}
+		}
+
+		fd, err := protodesc.NewFile(fdp, resolver)
+		if err == nil {
+			err = files.RegisterFile(fd)
+		}
+		if err != nil {
+			state[name] = failed
+			res.Errors = append(res.Errors, fmt.Sprintf("build files registry: %v", err))
+			return false
+		}
+		state[name] = done
+		return true
+	}
+
+	for _, name := range order {
+		visit(name)
+	}
+	return files
+}
+
+func (f fallbackResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := f.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return f.base.FindFileByPath(path)
+}
+
+func (f fallbackResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := f.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return f.base.FindDescriptorByName(name)
+}
+
+// typeDigests fingerprints every message type in files, including nested
+// ones, so reloads can report which types changed.
+func typeDigests(files *protoregistry.Files) map[string][32]byte {
+	out := make(map[string][32]byte)
+
+	var walk func(msgs protoreflect.MessageDescriptors)
+	walk = func(msgs protoreflect.MessageDescriptors) {
+		for i := 0; i < msgs.Len(); i++ {
+			md := msgs.Get(i)
+			b, _ := proto.MarshalOptions{Deterministic: true}.Marshal(protodesc.ToDescriptorProto(md))
+			out[string(md.FullName())] = sha256.Sum256(b)
+			walk(md.Messages())
+		}
+	}
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		walk(fd.Messages())
+		return true
+	})
+	return out
 }
 
 // FormatJSON parses the given payload as the given full type name and returns JSON bytes.
 func (r *Registry) FormatJSON(typeName string, payload []byte) ([]byte, error) {
+	return r.Render(typeName, payload, RenderOptions{})
+}
+
+// decode parses payload as a dynamic message of the given full type name.
+func (r *Registry) decode(typeName string, payload []byte) (*dynamicpb.Message, error) {
+	md, err := r.findMessage(typeName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAs(md, payload)
+}
+
+// findMessage looks up a message type in the loaded files, falling back to
+// the types linked into the collector.
+func (r *Registry) findMessage(typeName string) (protoreflect.MessageDescriptor, error) {
 	if r == nil {
 		return nil, fmt.Errorf("registry is nil")
 	}
@@ -107,7 +463,14 @@ func (r *Registry) FormatJSON(typeName string, payload []byte) ([]byte, error) {
 		return nil, fmt.Errorf("empty type name")
 	}
 
-	desc, err := r.files.FindDescriptorByName(protoreflect.FullName(typeName))
+	desc, err := r.files.Load().FindDescriptorByName(protoreflect.FullName(typeName))
+	if err != nil {
+		// Types linked into the collector itself (e.g. google.protobuf.Struct
+		// produced by the ingest bridges) need not be in a descriptor set.
+		if gd, gerr := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(typeName)); gerr == nil {
+			desc, err = gd, nil
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("find descriptor %q: %w", typeName, err)
 	}
@@ -116,17 +479,14 @@ func (r *Registry) FormatJSON(typeName string, payload []byte) ([]byte, error) {
 	if !ok {
 		return nil, fmt.Errorf("descriptor %q is not a message", typeName)
 	}
+	return msgDesc, nil
+}
 
-	msg := dynamicpb.NewMessage(msgDesc)
+// decodeAs parses payload as a dynamic message of type md.
+func decodeAs(md protoreflect.MessageDescriptor, payload []byte) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(md)
 	if err := proto.Unmarshal(payload, msg); err != nil {
-		return nil, fmt.Errorf("unmarshal payload as %q: %w", typeName, err)
-	}
-
-	opts := protojson.MarshalOptions{
-		Multiline:       false,
-		Indent:          "",
-		EmitUnpopulated: false,
-		UseProtoNames:   true,
+		return nil, fmt.Errorf("unmarshal payload as %q: %w", md.FullName(), err)
 	}
-	return opts.Marshal(msg)
+	return msg, nil
 }
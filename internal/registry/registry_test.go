@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/Espeer5/protolog/internal/registry"
@@ -93,3 +94,36 @@ func TestFormatJSON_UnknownType(t *testing.T) {
 		t.Fatalf("expected error for unknown type, got nil")
 	}
 }
+
+func TestFormatJSON_LinkedWellKnownType(t *testing.T) {
+	descPath := descriptorPath()
+	if _, err := os.Stat(descPath); os.IsNotExist(err) {
+		t.Skipf("schema descriptor %q not found; run `make proto` first", descPath)
+	}
+
+	reg, err := registry.NewFromFile(descPath)
+	if err != nil {
+		t.Fatalf("NewFromFile(%q) failed: %v", descPath, err)
+	}
+
+	s, err := structpb.NewStruct(map[string]any{"order": "o-1"})
+	if err != nil {
+		t.Fatalf("structpb.NewStruct failed: %v", err)
+	}
+	payload, err := proto.Marshal(s)
+	if err != nil {
+		t.Fatalf("proto.Marshal(Struct) failed: %v", err)
+	}
+
+	jsonBytes, err := reg.FormatJSON("google.protobuf.Struct", payload)
+	if err != nil {
+		t.Fatalf("FormatJSON(google.protobuf.Struct) failed: %v", err)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(jsonBytes, &obj); err != nil {
+		t.Fatalf("json.Unmarshal returned error: %v", err)
+	}
+	if got := obj["order"]; got != "o-1" {
+		t.Errorf("unexpected order in JSON: got %v, want %q", got, "o-1")
+	}
+}
@@ -0,0 +1,145 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+// makeSet builds a descriptor set with one file "<pkg>.proto" in package
+// pkg, holding a message per entry of msgs (name -> string field names).
+func makeSet(pkg string, msgs map[string][]string, deps ...string) *descriptorpb.FileDescriptorSet {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(pkg + ".proto"),
+		Package:    proto.String(pkg),
+		Syntax:     proto.String("proto3"),
+		Dependency: deps,
+	}
+	for name, fields := range msgs {
+		md := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+		for i, f := range fields {
+			md.Field = append(md.Field, &descriptorpb.FieldDescriptorProto{
+				Name:   proto.String(f),
+				Number: proto.Int32(int32(i + 1)),
+				Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			})
+		}
+		fdp.MessageType = append(fdp.MessageType, md)
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{fdp}}
+}
+
+// writeSet writes makeSet's result to path.
+func writeSet(t *testing.T, path, pkg string, msgs map[string][]string, deps ...string) {
+	t.Helper()
+
+	b, err := proto.Marshal(makeSet(pkg, msgs, deps...))
+	if err != nil {
+		t.Fatalf("marshal descriptor set: %v", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatalf("write descriptor set: %v", err)
+	}
+}
+
+func TestOpen_PartialFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeSet(t, filepath.Join(dir, "a.desc"), "a", map[string][]string{"M": {"x"}})
+	if err := os.WriteFile(filepath.Join(dir, "broken.desc"), []byte("not a descriptor"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// b.proto imports a file nobody provides.
+	writeSet(t, filepath.Join(dir, "b.desc"), "b", map[string][]string{"N": {"y"}}, "missing.proto")
+
+	reg, res := registry.Open([]string{dir, filepath.Join(dir, "absent.desc")})
+	if reg == nil {
+		t.Fatal("Open returned nil registry")
+	}
+	if res.Files != 1 || !reflect.DeepEqual(res.Added, []string{"a.M"}) {
+		t.Errorf("result = %+v, want only a.M loaded", res)
+	}
+	if len(res.Errors) != 3 {
+		t.Errorf("got %d errors, want 3: %q", len(res.Errors), res.Errors)
+	}
+	if _, err := reg.FormatJSON("a.M", nil); err != nil {
+		t.Errorf("FormatJSON(a.M) failed: %v", err)
+	}
+}
+
+func TestOpen_SharedImportWithSourceInfo(t *testing.T) {
+	dir := t.TempDir()
+	for i, name := range []string{"a.desc", "b.desc"} {
+		set := makeSet("common", map[string][]string{"C": {"x"}})
+		if i == 1 {
+			// Only one of the sets was built with --include_source_info.
+			set.File[0].SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+				Location: []*descriptorpb.SourceCodeInfo_Location{{Path: []int32{4, 0}, Span: []int32{1, 0, 10}}},
+			}
+		}
+		b, err := proto.Marshal(set)
+		if err != nil {
+			t.Fatalf("marshal descriptor set: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, res := registry.Open([]string{dir})
+	if len(res.Errors) != 0 {
+		t.Errorf("Open errors = %q, want none", res.Errors)
+	}
+}
+
+func TestReload_ReportsChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.desc")
+	writeSet(t, path, "a", map[string][]string{"M": {"x"}, "Gone": {"z"}})
+
+	reg, _ := registry.Open([]string{dir})
+
+	writeSet(t, path, "a", map[string][]string{"M": {"x", "y"}, "New": {"n"}})
+	res := reg.Reload()
+
+	if !reflect.DeepEqual(res.Added, []string{"a.New"}) ||
+		!reflect.DeepEqual(res.Changed, []string{"a.M"}) ||
+		!reflect.DeepEqual(res.Removed, []string{"a.Gone"}) {
+		t.Errorf("result = %+v", res)
+	}
+	if _, err := reg.FormatJSON("a.Gone", nil); err == nil {
+		t.Errorf("removed type still resolvable")
+	}
+}
+
+func TestWatch_ReloadsOnNewFile(t *testing.T) {
+	dir := t.TempDir()
+	reg, res := registry.Open([]string{dir})
+	if res.Types != 0 {
+		t.Fatalf("empty dir loaded %d types", res.Types)
+	}
+	if err := reg.Watch(); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer reg.Close()
+
+	writeSet(t, filepath.Join(dir, "w.desc"), "w", map[string][]string{"M": {"x"}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := reg.FormatJSON("w.M", nil); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("registry did not pick up new descriptor set")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
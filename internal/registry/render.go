@@ -0,0 +1,313 @@
+/*******************************************************************************
+*  internal/registry/render.go
+*
+*  Payload renderers. A decoded (and redacted) payload can be rendered as
+*  protojson with per-request options, as text format, or flattened into one
+*  "path = value" line per scalar, e.g. "readings[0].value = 3.5".
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Format selects a renderer.
+type Format string
+
+const (
+	RenderJSON Format = "json" // protojson (the default)
+	RenderText Format = "text" // prototext, multi-line
+	RenderFlat Format = "flat" // one "path = value" line per scalar
+)
+
+// RenderOptions configures how payloads are rendered. The zero value renders
+// compact protojson with proto field names and without unpopulated fields.
+type RenderOptions struct {
+	Format Format `json:"format,omitempty"`
+
+	// EmitDefaults includes unpopulated fields (json and flat).
+	EmitDefaults bool `json:"emit_defaults,omitempty"`
+
+	// EnumsAsNumbers renders enum values as numbers (json and flat).
+	EnumsAsNumbers bool `json:"enums_as_numbers,omitempty"`
+
+	// CamelCase uses lowerCamelCase JSON names instead of proto field
+	// names (json and flat).
+	CamelCase bool `json:"camel_case,omitempty"`
+}
+
+// Renderer renders a decoded message.
+type Renderer interface {
+	Render(msg protoreflect.Message) ([]byte, error)
+}
+
+type jsonRenderer struct{ opts protojson.MarshalOptions }
+
+type textRenderer struct{ opts prototext.MarshalOptions }
+
+type flatRenderer struct {
+	opts     RenderOptions
+	resolver Resolver
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// ParseFormat validates a format name; "" means RenderJSON.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return RenderJSON, nil
+	case RenderJSON, RenderText, RenderFlat:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown payload format %q (want json, text or flat)", s)
+}
+
+// IsJSON reports whether o renders JSON.
+func (o RenderOptions) IsJSON() bool {
+	return o.Format == "" || o.Format == RenderJSON
+}
+
+// Renderer returns the renderer for o, resolving google.protobuf.Any values
+// with the registry's types.
+func (r *Registry) Renderer(o RenderOptions) Renderer {
+	resolver := r.Resolver()
+	switch o.Format {
+	case RenderText:
+		return textRenderer{opts: prototext.MarshalOptions{
+			Multiline: true,
+			Indent:    "  ",
+			Resolver:  resolver,
+		}}
+	case RenderFlat:
+		return flatRenderer{opts: o, resolver: resolver}
+	}
+	return jsonRenderer{opts: protojson.MarshalOptions{
+		EmitUnpopulated: o.EmitDefaults,
+		UseEnumNumbers:  o.EnumsAsNumbers,
+		UseProtoNames:   !o.CamelCase,
+		Resolver:        resolver,
+	}}
+}
+
+// Render decodes payload as typeName, redacts it and renders it with o.
+func (r *Registry) Render(typeName string, payload []byte, o RenderOptions) ([]byte, error) {
+	msg, err := r.decode(typeName, payload)
+	if err != nil {
+		return nil, err
+	}
+	return r.render(msg, o)
+}
+
+// RenderAt is Render using the version of typeName that was current at event
+// time tsMs (Unix milliseconds).
+func (r *Registry) RenderAt(typeName string, payload []byte, tsMs int64, o RenderOptions) ([]byte, error) {
+	if r == nil {
+		return nil, fmt.Errorf("registry is nil")
+	}
+
+	md, err := r.descriptorAt(typeName, tsMs)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return r.Render(typeName, payload, o)
+	}
+
+	msg, err := decodeAs(md, payload)
+	if err != nil {
+		return nil, err
+	}
+	return r.render(msg, o)
+}
+
+// render redacts msg and renders it with o.
+func (r *Registry) render(msg proto.Message, o RenderOptions) ([]byte, error) {
+	r.redactor.MessageWithTypes(msg.ProtoReflect(), r.Resolver())
+	return r.Renderer(o).Render(msg.ProtoReflect())
+}
+
+func (j jsonRenderer) Render(msg protoreflect.Message) ([]byte, error) {
+	return j.opts.Marshal(msg.Interface())
+}
+
+func (t textRenderer) Render(msg protoreflect.Message) ([]byte, error) {
+	return t.opts.Marshal(msg.Interface())
+}
+
+func (f flatRenderer) Render(msg protoreflect.Message) ([]byte, error) {
+	var lines []string
+	if err := f.message("", msg, &lines); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return []byte{}, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// message appends the lines for msg, whose path is prefix.
+func (f flatRenderer) message(prefix string, msg protoreflect.Message, lines *[]string) error {
+	md := msg.Descriptor()
+
+	// Well-known types (Timestamp, Duration, Struct, ...) have idiomatic
+	// JSON forms that read better than their raw fields.
+	if md.ParentFile().Package() == "google.protobuf" && md.FullName() != anyFullName {
+		b, err := protojson.MarshalOptions{Resolver: f.resolver}.Marshal(msg.Interface())
+		if err != nil {
+			return err
+		}
+		*lines = append(*lines, fmt.Sprintf("%s = %s", f.key(prefix), b))
+		return nil
+	}
+
+	if md.FullName() == anyFullName {
+		fields := md.Fields()
+		url := msg.Get(fields.ByName("type_url")).String()
+		if mt, err := f.resolver.FindMessageByURL(url); err == nil {
+			inner := mt.New()
+			if err := proto.Unmarshal(msg.Get(fields.ByName("value")).Bytes(), inner.Interface()); err == nil {
+				*lines = append(*lines, fmt.Sprintf("%s = %s", join(prefix, "@type"), strconv.Quote(url)))
+				return f.message(prefix, inner, lines)
+			}
+		}
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if !msg.Has(fd) && !(f.opts.EmitDefaults && fd.ContainingOneof() == nil) {
+			continue
+		}
+		name := string(fd.Name())
+		if f.opts.CamelCase {
+			name = fd.JSONName()
+		}
+		path := join(prefix, name)
+		v := msg.Get(fd)
+
+		if !msg.Has(fd) {
+			// EmitDefaults: show empty containers and unset messages
+			// like protojson does, without expanding their defaults.
+			switch {
+			case fd.IsMap():
+				*lines = append(*lines, path+" = {}")
+			case fd.IsList():
+				*lines = append(*lines, path+" = []")
+			case fd.Message() != nil:
+				*lines = append(*lines, path+" = null")
+			default:
+				*lines = append(*lines, fmt.Sprintf("%s = %s", path, f.scalar(fd, v)))
+			}
+			continue
+		}
+
+		switch {
+		case fd.IsMap():
+			m := v.Map()
+			keys := make([]protoreflect.MapKey, 0, m.Len())
+			m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				keys = append(keys, k)
+				return true
+			})
+			sort.Slice(keys, func(a, b int) bool { return mapKeyLess(keys[a], keys[b]) })
+			for _, k := range keys {
+				kp := fmt.Sprintf("%s[%s]", path, f.scalar(fd.MapKey(), k.Value()))
+				if err := f.value(kp, fd.MapValue(), m.Get(k), lines); err != nil {
+					return err
+				}
+			}
+		case fd.IsList():
+			l := v.List()
+			for j := 0; j < l.Len(); j++ {
+				if err := f.value(fmt.Sprintf("%s[%d]", path, j), fd, l.Get(j), lines); err != nil {
+					return err
+				}
+			}
+		default:
+			if err := f.value(path, fd, v, lines); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f flatRenderer) value(path string, fd protoreflect.FieldDescriptor, v protoreflect.Value, lines *[]string) error {
+	if fd.Message() != nil {
+		return f.message(path, v.Message(), lines)
+	}
+	*lines = append(*lines, fmt.Sprintf("%s = %s", path, f.scalar(fd, v)))
+	return nil
+}
+
+// scalar formats a non-message value: strings quoted, bytes in base64,
+// enums by name unless EnumsAsNumbers (or the number is unknown).
+func (f flatRenderer) scalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(base64.StdEncoding.EncodeToString(v.Bytes()))
+	case protoreflect.EnumKind:
+		if !f.opts.EnumsAsNumbers {
+			if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+				return string(ev.Name())
+			}
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32)
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return v.String()
+}
+
+func (f flatRenderer) key(prefix string) string {
+	if prefix == "" {
+		return "."
+	}
+	return prefix
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+func mapKeyLess(a, b protoreflect.MapKey) bool {
+	switch av := a.Interface().(type) {
+	case string:
+		return av < b.String()
+	case bool:
+		return !av && b.Bool()
+	case int32, int64:
+		return a.Int() < b.Int()
+	case uint32, uint64:
+		return a.Uint() < b.Uint()
+	}
+	return false
+}
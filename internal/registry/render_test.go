@@ -0,0 +1,138 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+// treePayload encodes tree.Node{name: "root", kind: LEAF,
+// children: [{name: "leaf"}], counts: {"b": 2, "a": 1}}.
+func treePayload(t testing.TB, reg *registry.Registry) []byte {
+	t.Helper()
+
+	mt, err := reg.Types().FindMessageByName("tree.Node")
+	if err != nil {
+		t.Fatalf("find tree.Node: %v", err)
+	}
+	fields := mt.Descriptor().Fields()
+	root := mt.New()
+	root.Set(fields.ByName("name"), protoreflect.ValueOfString("root"))
+	root.Set(fields.ByName("kind"), protoreflect.ValueOfEnum(1))
+	child := root.Mutable(fields.ByName("children")).List().NewElement()
+	child.Message().Set(fields.ByName("name"), protoreflect.ValueOfString("leaf"))
+	root.Mutable(fields.ByName("children")).List().Append(child)
+	counts := root.Mutable(fields.ByName("counts")).Map()
+	counts.Set(protoreflect.ValueOfString("b").MapKey(), protoreflect.ValueOfInt64(2))
+	counts.Set(protoreflect.ValueOfString("a").MapKey(), protoreflect.ValueOfInt64(1))
+
+	b, err := proto.Marshal(root.Interface())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRender(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.desc")
+	b, err := proto.Marshal(treeSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, _ := registry.Open([]string{path})
+	payload := treePayload(t, reg)
+
+	flat, err := reg.Render("tree.Node", payload, registry.RenderOptions{Format: registry.RenderFlat})
+	if err != nil {
+		t.Fatalf("Render(flat) failed: %v", err)
+	}
+	want := `name = "root"
+children[0].name = "leaf"
+counts["a"] = 1
+counts["b"] = 2
+kind = LEAF
+`
+	if string(flat) != want {
+		t.Errorf("Render(flat) =\n%s\nwant\n%s", flat, want)
+	}
+
+	flat, err = reg.Render("tree.Node", payload, registry.RenderOptions{
+		Format: registry.RenderFlat, EmitDefaults: true, EnumsAsNumbers: true,
+	})
+	if err != nil {
+		t.Fatalf("Render(flat, defaults) failed: %v", err)
+	}
+	for _, line := range []string{"kind = 1", "children[0].kind = 0", "children[0].children = []",
+		"children[0].counts = {}"} {
+		if !strings.Contains(string(flat), line+"\n") {
+			t.Errorf("Render(flat, defaults) lacks %q:\n%s", line, flat)
+		}
+	}
+	if strings.Contains(string(flat), "weight") {
+		t.Errorf("Render(flat, defaults) shows unset optional field:\n%s", flat)
+	}
+
+	js, err := reg.Render("tree.Node", payload, registry.RenderOptions{EnumsAsNumbers: true})
+	if err != nil {
+		t.Fatalf("Render(json) failed: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(js, &got); err != nil {
+		t.Fatalf("Render(json) output is not JSON: %v", err)
+	}
+	if got["kind"] != float64(1) || !reflect.DeepEqual(got["counts"], map[string]any{"a": "1", "b": "2"}) {
+		t.Errorf("Render(json) = %s", js)
+	}
+
+	text, err := reg.Render("tree.Node", payload, registry.RenderOptions{Format: registry.RenderText})
+	if err != nil {
+		t.Fatalf("Render(text) failed: %v", err)
+	}
+	// prototext randomizes its whitespace.
+	words := strings.Join(strings.Fields(string(text)), " ")
+	if !strings.Contains(words, `name: "root"`) || !strings.Contains(words, "kind: LEAF") {
+		t.Errorf("Render(text) =\n%s", text)
+	}
+
+	if _, err := registry.ParseFormat("yaml"); err == nil {
+		t.Errorf("ParseFormat(yaml) succeeded")
+	}
+}
+
+func TestRender_CamelCase(t *testing.T) {
+	reg := loadRegistry(t)
+
+	payload, err := proto.Marshal(mustLoginEvent(t, reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := reg.Render("demo.LoginEvent", payload, registry.RenderOptions{CamelCase: true})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if !strings.Contains(string(js), `"clientIp"`) {
+		t.Errorf("Render(camel) = %s", js)
+	}
+}
+
+func mustLoginEvent(t *testing.T, reg *registry.Registry) proto.Message {
+	t.Helper()
+	mt, err := reg.Types().FindMessageByName("demo.LoginEvent")
+	if err != nil {
+		t.Fatalf("find demo.LoginEvent: %v", err)
+	}
+	m := mt.New()
+	m.Set(mt.Descriptor().Fields().ByName("client_ip"), protoreflect.ValueOfString("10.0.0.1"))
+	return m.Interface()
+}
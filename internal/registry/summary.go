@@ -0,0 +1,141 @@
+/*******************************************************************************
+*  internal/registry/summary.go
+*
+*  Per-type summary templates. A text/template evaluated against the decoded
+*  (and redacted) payload produces the summary of envelopes whose producer
+*  sent none, or replaces the producer's summary for display when configured
+*  as an override. Payload fields are addressed by proto name, e.g.
+*  "{{.name}}={{.value}}{{.unit}}" for demo.Metric.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// SummaryTemplate configures the summary of one message type.
+type SummaryTemplate struct {
+	Type     string // message full name
+	Template string // text/template source
+
+	// Override replaces non-empty producer summaries for display; otherwise
+	// the template only fills in empty summaries.
+	Override bool
+}
+
+// SummaryTemplates holds compiled summary templates by message type. A nil
+// *SummaryTemplates leaves summaries alone.
+type SummaryTemplates struct {
+	byType map[string]summaryTemplate
+}
+
+type summaryTemplate struct {
+	tmpl     *template.Template
+	override bool
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// ParseSummaryTemplate parses a "TYPE=TEMPLATE" definition.
+func ParseSummaryTemplate(def string, override bool) (SummaryTemplate, error) {
+	typeName, src, ok := strings.Cut(def, "=")
+	if !ok || typeName == "" {
+		return SummaryTemplate{}, fmt.Errorf("summary template %q: want TYPE=TEMPLATE", def)
+	}
+	return SummaryTemplate{Type: typeName, Template: src, Override: override}, nil
+}
+
+// NewSummaryTemplates compiles defs. A later definition for the same type
+// replaces an earlier one.
+func NewSummaryTemplates(defs []SummaryTemplate) (*SummaryTemplates, error) {
+	st := &SummaryTemplates{byType: make(map[string]summaryTemplate, len(defs))}
+	for _, d := range defs {
+		tmpl, err := template.New(d.Type).Option("missingkey=zero").Parse(d.Template)
+		if err != nil {
+			return nil, fmt.Errorf("summary template for %s: %w", d.Type, err)
+		}
+		st.byType[d.Type] = summaryTemplate{tmpl: tmpl, override: d.Override}
+	}
+	return st, nil
+}
+
+// SetSummaryTemplates installs st; see FillSummary and DisplaySummary.
+func (r *Registry) SetSummaryTemplates(st *SummaryTemplates) {
+	if r != nil {
+		r.summaries = st
+	}
+}
+
+// FillSummary returns summary, or the templated summary of the payload if
+// summary is empty and typeName has a template. It is applied at ingest,
+// so the computed summary is stored and searchable.
+func (r *Registry) FillSummary(typeName string, payload []byte, summary string) string {
+	if summary != "" {
+		return summary
+	}
+	return r.summarize(typeName, payload, time.Now().UnixMilli(), summary, false)
+}
+
+// DisplaySummary returns the summary to show for a payload logged at tsMs:
+// the templated summary if typeName has an override template, else summary.
+// Stored summaries are left untouched.
+func (r *Registry) DisplaySummary(typeName string, payload []byte, tsMs int64, summary string) string {
+	return r.summarize(typeName, payload, tsMs, summary, true)
+}
+
+func (r *Registry) summarize(typeName string, payload []byte, tsMs int64, summary string, overrideOnly bool) string {
+	if r == nil || r.summaries == nil {
+		return summary
+	}
+	t, ok := r.summaries.byType[typeName]
+	if !ok || (overrideOnly && !t.override) {
+		return summary
+	}
+
+	s, err := r.executeSummary(t.tmpl, typeName, payload, tsMs)
+	if err != nil {
+		log.Printf("summary template for %s: %v", typeName, err)
+		return summary
+	}
+	return s
+}
+
+// executeSummary runs tmpl on the payload decoded into a map keyed by proto
+// field names, with unpopulated fields present as their defaults. Numbers
+// keep their JSON spelling, so 64-bit values and floats print exactly.
+func (r *Registry) executeSummary(tmpl *template.Template, typeName string, payload []byte, tsMs int64) (string, error) {
+	b, err := r.RenderAt(typeName, payload, tsMs, RenderOptions{EmitDefaults: true})
+	if err != nil {
+		return "", err
+	}
+
+	var data map[string]any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
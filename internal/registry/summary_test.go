@@ -0,0 +1,91 @@
+package registry_test
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+func TestSummaryTemplates(t *testing.T) {
+	reg := loadRegistry(t)
+	rd, err := registry.NewRedactor(registry.RedactionRules{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetRedactor(rd)
+
+	var defs []registry.SummaryTemplate
+	for _, d := range []struct {
+		def      string
+		override bool
+	}{
+		{"demo.Metric={{.name}}={{.value}}{{.unit}}", false},
+		{"demo.LoginEvent=login {{.user}} pw={{.password}}", true},
+	} {
+		st, err := registry.ParseSummaryTemplate(d.def, d.override)
+		if err != nil {
+			t.Fatalf("ParseSummaryTemplate failed: %v", err)
+		}
+		defs = append(defs, st)
+	}
+	st, err := registry.NewSummaryTemplates(defs)
+	if err != nil {
+		t.Fatalf("NewSummaryTemplates failed: %v", err)
+	}
+	reg.SetSummaryTemplates(st)
+
+	encode := func(typeName string, fields map[string]protoreflect.Value) []byte {
+		mt, err := reg.Types().FindMessageByName(protoreflect.FullName(typeName))
+		if err != nil {
+			t.Fatalf("find %s: %v", typeName, err)
+		}
+		m := mt.New()
+		for name, v := range fields {
+			m.Set(mt.Descriptor().Fields().ByName(protoreflect.Name(name)), v)
+		}
+		b, err := proto.Marshal(m.Interface())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	metric := encode("demo.Metric", map[string]protoreflect.Value{
+		"name":  protoreflect.ValueOfString("cpu"),
+		"value": protoreflect.ValueOfFloat64(42.5),
+		"unit":  protoreflect.ValueOfString("%"),
+	})
+	if got := reg.FillSummary("demo.Metric", metric, ""); got != "cpu=42.5%" {
+		t.Errorf("FillSummary = %q, want %q", got, "cpu=42.5%")
+	}
+	if got := reg.FillSummary("demo.Metric", metric, "producer"); got != "producer" {
+		t.Errorf("FillSummary replaced a producer summary: %q", got)
+	}
+	if got := reg.DisplaySummary("demo.Metric", metric, 0, "producer"); got != "producer" {
+		t.Errorf("DisplaySummary without override = %q", got)
+	}
+
+	login := encode("demo.LoginEvent", map[string]protoreflect.Value{
+		"user":     protoreflect.ValueOfString("alice"),
+		"password": protoreflect.ValueOfString("hunter2"),
+	})
+	want := "login alice pw=" + registry.RedactedValue
+	if got := reg.DisplaySummary("demo.LoginEvent", login, 0, "producer"); got != want {
+		t.Errorf("DisplaySummary = %q, want %q", got, want)
+	}
+
+	// Broken payloads keep the original summary.
+	if got := reg.DisplaySummary("demo.LoginEvent", []byte{0xff}, 0, "producer"); got != "producer" {
+		t.Errorf("DisplaySummary(broken) = %q", got)
+	}
+
+	if _, err := registry.ParseSummaryTemplate("no-separator", false); err == nil {
+		t.Errorf("ParseSummaryTemplate accepted a definition without '='")
+	}
+	if _, err := registry.NewSummaryTemplates([]registry.SummaryTemplate{{Type: "x", Template: "{{"}}); err == nil {
+		t.Errorf("NewSummaryTemplates accepted a broken template")
+	}
+}
@@ -0,0 +1,134 @@
+/*******************************************************************************
+*  internal/registry/types.go
+*
+*  Message, enum and extension types built from the loaded descriptors. They
+*  back the resolver used when rendering payloads, so google.protobuf.Any
+*  values packing registry types are expanded instead of failing to render.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+const anyFullName protoreflect.FullName = "google.protobuf.Any"
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Resolver resolves types against the registry's types first and then the
+// types linked into the collector. It satisfies the resolver interfaces of
+// protojson, prototext and proto.UnmarshalOptions.
+type Resolver struct {
+	types *protoregistry.Types
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// buildTypes creates dynamic types for every message, enum and extension
+// declared in files.
+func buildTypes(files *protoregistry.Files, res *ReloadResult) *protoregistry.Types {
+	types := new(protoregistry.Types)
+	note := func(err error) {
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("build types registry: %v", err))
+		}
+	}
+
+	var exts func(xs protoreflect.ExtensionDescriptors)
+	exts = func(xs protoreflect.ExtensionDescriptors) {
+		for i := 0; i < xs.Len(); i++ {
+			note(types.RegisterExtension(dynamicpb.NewExtensionType(xs.Get(i))))
+		}
+	}
+	var msgs func(ms protoreflect.MessageDescriptors)
+	msgs = func(ms protoreflect.MessageDescriptors) {
+		for i := 0; i < ms.Len(); i++ {
+			md := ms.Get(i)
+			exts(md.Extensions())
+			msgs(md.Messages())
+		}
+	}
+
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		forEachDecl(fd, func(d protoreflect.Descriptor) {
+			switch d := d.(type) {
+			case protoreflect.MessageDescriptor:
+				if !d.IsMapEntry() {
+					note(types.RegisterMessage(dynamicpb.NewMessageType(d)))
+				}
+			case protoreflect.EnumDescriptor:
+				note(types.RegisterEnum(dynamicpb.NewEnumType(d)))
+			}
+		})
+		exts(fd.Extensions())
+		msgs(fd.Messages())
+		return true
+	})
+	return types
+}
+
+// Types returns the types built from the currently loaded descriptors.
+func (r *Registry) Types() *protoregistry.Types {
+	if r == nil {
+		return nil
+	}
+	return r.types.Load()
+}
+
+// Resolver returns a resolver over Types backed by the global types.
+func (r *Registry) Resolver() Resolver {
+	return Resolver{types: r.Types()}
+}
+
+func (res Resolver) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	if res.types != nil {
+		if mt, err := res.types.FindMessageByName(name); err == nil {
+			return mt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindMessageByName(name)
+}
+
+func (res Resolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	if res.types != nil {
+		if mt, err := res.types.FindMessageByURL(url); err == nil {
+			return mt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindMessageByURL(url)
+}
+
+func (res Resolver) FindExtensionByName(name protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if res.types != nil {
+		if xt, err := res.types.FindExtensionByName(name); err == nil {
+			return xt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(name)
+}
+
+func (res Resolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if res.types != nil {
+		if xt, err := res.types.FindExtensionByNumber(message, field); err == nil {
+			return xt, nil
+		}
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
@@ -0,0 +1,118 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+func TestFormatJSON_AnyAndWellKnownTypes(t *testing.T) {
+	msgField := func(name string, num int32, typeName string) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(num),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			TypeName: proto.String(typeName),
+		}
+	}
+	set := makeSet("env", map[string][]string{"Secret": {"note"}})
+	fdp := set.File[0]
+	fdp.Dependency = []string{"google/protobuf/any.proto", "google/protobuf/duration.proto",
+		"google/protobuf/timestamp.proto", "google/protobuf/struct.proto"}
+	fdp.MessageType = append(fdp.MessageType, &descriptorpb.DescriptorProto{
+		Name: proto.String("Wrapper"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			msgField("inner", 1, ".google.protobuf.Any"),
+			msgField("took", 2, ".google.protobuf.Duration"),
+			msgField("at", 3, ".google.protobuf.Timestamp"),
+			msgField("attrs", 4, ".google.protobuf.Struct"),
+		},
+	})
+
+	path := filepath.Join(t.TempDir(), "env.desc")
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg, res := registry.Open([]string{path})
+	if len(res.Errors) > 0 {
+		t.Fatalf("Open failed: %v", res.Errors)
+	}
+	rd, err := registry.NewRedactor(registry.RedactionRules{Fields: []string{"env.Secret.note"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetRedactor(rd)
+
+	if _, err := reg.Types().FindMessageByName("env.Wrapper"); err != nil {
+		t.Fatalf("Types() does not contain env.Wrapper: %v", err)
+	}
+
+	secret := protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), "hunter2")
+	attrs, _ := structpb.NewStruct(map[string]any{"k": "v"})
+	var payload []byte
+	for i, m := range []proto.Message{
+		&anypb.Any{TypeUrl: "type.googleapis.com/env.Secret", Value: secret},
+		durationpb.New(1500 * time.Millisecond),
+		timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		attrs,
+	} {
+		mb, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payload = protowire.AppendTag(payload, protowire.Number(i+1), protowire.BytesType)
+		payload = protowire.AppendBytes(payload, mb)
+	}
+
+	out, err := reg.FormatJSON("env.Wrapper", payload)
+	if err != nil {
+		t.Fatalf("FormatJSON failed: %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("FormatJSON output is not JSON: %v", err)
+	}
+	want := map[string]any{
+		"inner": map[string]any{"@type": "type.googleapis.com/env.Secret", "note": registry.RedactedValue},
+		"took":  "1.500s",
+		"at":    "2024-01-02T03:04:05Z",
+		"attrs": map[string]any{"k": "v"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FormatJSON = %s", out)
+	}
+
+	// A payload that is itself an Any is redacted too.
+	top, err := proto.Marshal(&anypb.Any{TypeUrl: "type.googleapis.com/env.Secret", Value: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []registry.RenderOptions{{}, {Format: registry.RenderText}, {Format: registry.RenderFlat}} {
+		out, err := reg.Render("google.protobuf.Any", top, o)
+		if err != nil {
+			t.Fatalf("Render(Any, %q) failed: %v", o.Format, err)
+		}
+		if strings.Contains(string(out), "hunter2") || !strings.Contains(string(out), registry.RedactedValue) {
+			t.Errorf("Render(Any, %q) = %s", o.Format, out)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+/*******************************************************************************
+*  internal/registry/uploads.go
+*
+*  Descriptor sets uploaded at runtime. Uploaded sets are validated against the
+*  files already loaded, stored as named .desc files in an upload directory
+*  (which is one of the registry's sources) and picked up by an immediate
+*  reload, so new message types become decodable without touching the host.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// FileInfo describes one loaded .proto file.
+type FileInfo struct {
+	Name     string   `json:"name"`
+	Package  string   `json:"package"`
+	Source   string   `json:"source"`          // descriptor set the file was loaded from
+	Set      string   `json:"set,omitempty"`   // upload name, if uploaded
+	Messages []string `json:"messages"`        // full names, including nested types
+	Enums    []string `json:"enums,omitempty"` // full names, including nested types
+}
+
+// chainResolver tries each resolver in turn.
+type chainResolver []protodesc.Resolver
+
+// excludingResolver resolves against files, hiding the given file paths and
+// everything they declare.
+type excludingResolver struct {
+	files   *protoregistry.Files
+	exclude map[string]bool
+}
+
+/*******************************************************************************
+*  ERRORS
+*******************************************************************************/
+
+var (
+	// ErrUploadsDisabled is returned when no upload directory is configured.
+	ErrUploadsDisabled = errors.New("schema uploads are not enabled")
+
+	// ErrSetNotFound is returned when deleting an unknown uploaded set.
+	ErrSetNotFound = errors.New("schema set not found")
+)
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+var setNameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// EnableUploads stores uploaded descriptor sets in dir, creating it if
+// needed, and adds dir to the registry's sources ahead of every other source
+// so that re-uploaded files take precedence. Call it before Watch.
+func (r *Registry) EnableUploads(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create schema upload dir: %w", err)
+	}
+
+	r.mu.Lock()
+	r.uploadDir = dir
+	r.sources = append([]string{dir}, r.sources...)
+	r.mu.Unlock()
+
+	r.loadFingerprints()
+	r.Reload()
+	return nil
+}
+
+// Validate checks that set links cleanly against the loaded files: every
+// import resolves, and no type clashes with a type from a file outside the
+// set. Files in set with the same name as a loaded file replace it.
+func (r *Registry) Validate(set *descriptorpb.FileDescriptorSet) error {
+	if len(set.GetFile()) == 0 {
+		return errors.New("descriptor set contains no files")
+	}
+
+	replaced := make(map[string]bool, len(set.File))
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(set.File))
+	order := make([]string, 0, len(set.File))
+	for _, fdp := range set.File {
+		name := fdp.GetName()
+		if name == "" {
+			return errors.New("descriptor set contains a file without a name")
+		}
+		if _, dup := protos[name]; dup {
+			return fmt.Errorf("file %q appears more than once", name)
+		}
+		replaced[name] = true
+		protos[name] = fdp
+		order = append(order, name)
+	}
+
+	current := r.files.Load()
+	if current == nil {
+		current = new(protoregistry.Files)
+	}
+	// Imports resolve against the loaded files (minus the ones being
+	// replaced) and then the linked-in files (well-known types).
+	var res ReloadResult
+	files := buildFiles(protos, order, chainResolver{
+		excludingResolver{files: current, exclude: replaced},
+		protoregistry.GlobalFiles,
+	}, &res)
+	if len(res.Errors) > 0 {
+		return errors.New(strings.Join(res.Errors, "; "))
+	}
+
+	var conflicts []string
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		forEachDecl(fd, func(d protoreflect.Descriptor) {
+			if old, err := current.FindDescriptorByName(d.FullName()); err == nil &&
+				!replaced[old.ParentFile().Path()] {
+				conflicts = append(conflicts, fmt.Sprintf("%s (already defined in %s)",
+					d.FullName(), old.ParentFile().Path()))
+			}
+		})
+		return true
+	})
+	if len(conflicts) > 0 {
+		return fmt.Errorf("conflicting definitions: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// AddSet validates set and stores it under name, replacing an earlier upload
+// of the same name, then reloads the registry. Under CompatReject, a set with
+// breaking changes to the types it replaces is refused with an
+// *IncompatibleError, also when the reload itself keeps the previous
+// version of one of its files; the upload is then undone.
+func (r *Registry) AddSet(name string, set *descriptorpb.FileDescriptorSet) (ReloadResult, error) {
+	if r.uploadDir == "" {
+		return ReloadResult{}, ErrUploadsDisabled
+	}
+	if !setNameRE.MatchString(name) {
+		return ReloadResult{}, fmt.Errorf("invalid schema set name %q", name)
+	}
+
+	r.uploadMu.Lock()
+	defer r.uploadMu.Unlock()
+
+	if err := r.Validate(set); err != nil {
+		return ReloadResult{}, err
+	}
+	if r.policy() == CompatReject {
+		changes, err := r.CheckSet(set)
+		if err != nil {
+			return ReloadResult{}, err
+		}
+		if HasBreaking(changes) {
+			return ReloadResult{}, &IncompatibleError{Changes: changes}
+		}
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("marshal descriptor set: %w", err)
+	}
+
+	path := filepath.Join(r.uploadDir, name+".desc")
+	old, oldErr := os.ReadFile(path)
+	if err := writeSet(path, b); err != nil {
+		return ReloadResult{}, err
+	}
+
+	// The check above ran against the files loaded before; if the reload
+	// keeps the previous version of one of the set's files, it failed.
+	res := r.Reload()
+	if err := r.rejectedUpload(set, res); err != nil {
+		if oldErr == nil {
+			_ = writeSet(path, old)
+		} else {
+			_ = os.Remove(path)
+		}
+		return res, err
+	}
+	return res, nil
+}
+
+// rejectedUpload returns an *IncompatibleError if res kept the previous
+// version of a file of set.
+func (r *Registry) rejectedUpload(set *descriptorpb.FileDescriptorSet, res ReloadResult) error {
+	ours := make(map[string]bool, len(set.GetFile()))
+	for _, fdp := range set.GetFile() {
+		ours[fdp.GetName()] = true
+	}
+	rejected := false
+	for _, path := range res.Rejected {
+		rejected = rejected || ours[path]
+	}
+	if !rejected {
+		return nil
+	}
+
+	var changes []Change
+	for _, c := range res.Compat {
+		d, err := r.files.Load().FindDescriptorByName(protoreflect.FullName(c.Type))
+		if c.Severity == Breaking && err == nil && ours[d.ParentFile().Path()] {
+			changes = append(changes, c)
+		}
+	}
+	return &IncompatibleError{Changes: changes}
+}
+
+// writeSet atomically replaces the descriptor set file at path with b.
+func writeSet(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return fmt.Errorf("write descriptor set: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write descriptor set: %w", err)
+	}
+	return nil
+}
+
+// RemoveSet deletes the uploaded set called name and reloads the registry.
+func (r *Registry) RemoveSet(name string) (ReloadResult, error) {
+	if r.uploadDir == "" {
+		return ReloadResult{}, ErrUploadsDisabled
+	}
+	if !setNameRE.MatchString(name) {
+		return ReloadResult{}, ErrSetNotFound
+	}
+
+	r.uploadMu.Lock()
+	defer r.uploadMu.Unlock()
+
+	err := os.Remove(filepath.Join(r.uploadDir, name+".desc"))
+	if errors.Is(err, os.ErrNotExist) {
+		return ReloadResult{}, ErrSetNotFound
+	}
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("remove descriptor set: %w", err)
+	}
+	r.forgetFingerprint(name)
+
+	return r.Reload(), nil
+}
+
+// Files lists the loaded files, sorted by name.
+func (r *Registry) Files() []FileInfo {
+	files := r.files.Load()
+	if files == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	origins := r.origins
+	uploadDir := r.uploadDir
+	r.mu.Unlock()
+
+	var out []FileInfo
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		fi := FileInfo{
+			Name:     fd.Path(),
+			Package:  string(fd.Package()),
+			Source:   origins[fd.Path()],
+			Messages: []string{},
+		}
+		if uploadDir != "" && filepath.Dir(fi.Source) == filepath.Clean(uploadDir) {
+			fi.Set = strings.TrimSuffix(filepath.Base(fi.Source), ".desc")
+		}
+		forEachDecl(fd, func(d protoreflect.Descriptor) {
+			switch d.(type) {
+			case protoreflect.MessageDescriptor:
+				fi.Messages = append(fi.Messages, string(d.FullName()))
+			case protoreflect.EnumDescriptor:
+				fi.Enums = append(fi.Enums, string(d.FullName()))
+			}
+		})
+		out = append(out, fi)
+		return true
+	})
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// forEachDecl calls fn for every message and enum declared in fd, including
+// nested ones.
+func forEachDecl(fd protoreflect.FileDescriptor, fn func(protoreflect.Descriptor)) {
+	enums := func(es protoreflect.EnumDescriptors) {
+		for i := 0; i < es.Len(); i++ {
+			fn(es.Get(i))
+		}
+	}
+	var msgs func(ms protoreflect.MessageDescriptors)
+	msgs = func(ms protoreflect.MessageDescriptors) {
+		for i := 0; i < ms.Len(); i++ {
+			md := ms.Get(i)
+			fn(md)
+			enums(md.Enums())
+			msgs(md.Messages())
+		}
+	}
+	enums(fd.Enums())
+	msgs(fd.Messages())
+}
+
+func (e excludingResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if e.exclude[path] {
+		return nil, protoregistry.NotFound
+	}
+	return e.files.FindFileByPath(path)
+}
+
+func (e excludingResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	d, err := e.files.FindDescriptorByName(name)
+	if err == nil && e.exclude[d.ParentFile().Path()] {
+		return nil, protoregistry.NotFound
+	}
+	return d, err
+}
+
+func (c chainResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, r := range c {
+		if fd, err := r.FindFileByPath(path); err == nil {
+			return fd, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+func (c chainResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, r := range c {
+		if d, err := r.FindDescriptorByName(name); err == nil {
+			return d, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
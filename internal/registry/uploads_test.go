@@ -0,0 +1,89 @@
+package registry_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+func TestUploads_AddValidateRemove(t *testing.T) {
+	dir := t.TempDir()
+	writeSet(t, filepath.Join(dir, "base.desc"), "base", map[string][]string{"M": {"x"}})
+
+	reg, _ := registry.Open([]string{filepath.Join(dir, "base.desc")})
+	if _, err := reg.AddSet("x", makeSet("x", nil)); !errors.Is(err, registry.ErrUploadsDisabled) {
+		t.Fatalf("AddSet without uploads = %v, want ErrUploadsDisabled", err)
+	}
+	if err := reg.EnableUploads(filepath.Join(dir, "uploads")); err != nil {
+		t.Fatalf("EnableUploads failed: %v", err)
+	}
+
+	// A new file importing an already loaded one links cleanly.
+	res, err := reg.AddSet("billing", makeSet("billing", map[string][]string{"Invoice": {"id"}}, "base.proto"))
+	if err != nil {
+		t.Fatalf("AddSet failed: %v", err)
+	}
+	if !reflect.DeepEqual(res.Added, []string{"billing.Invoice"}) {
+		t.Errorf("Added = %v, want [billing.Invoice]", res.Added)
+	}
+	if _, err := reg.FormatJSON("billing.Invoice", nil); err != nil {
+		t.Errorf("uploaded type not resolvable: %v", err)
+	}
+
+	var found bool
+	for _, f := range reg.Files() {
+		if f.Name == "billing.proto" {
+			found = f.Set == "billing" && reflect.DeepEqual(f.Messages, []string{"billing.Invoice"})
+		}
+	}
+	if !found {
+		t.Errorf("Files() does not list billing.proto from set billing: %+v", reg.Files())
+	}
+
+	// Rejected uploads.
+	bad := map[string]error{
+		"missing import": func() error {
+			_, err := reg.AddSet("y", makeSet("y", map[string][]string{"Y": {"a"}}, "nope.proto"))
+			return err
+		}(),
+		"type conflict": func() error {
+			set := makeSet("base", map[string][]string{"M": {"other"}})
+			set.File[0].Name = stringPtr("dup.proto")
+			_, err := reg.AddSet("dup", set)
+			return err
+		}(),
+		"bad name": func() error {
+			_, err := reg.AddSet("../escape", makeSet("z", nil))
+			return err
+		}(),
+	}
+	for name, err := range bad {
+		if err == nil {
+			t.Errorf("%s: AddSet succeeded, want error", name)
+		}
+	}
+
+	// Re-uploading a set replaces it.
+	res, err = reg.AddSet("billing", makeSet("billing", map[string][]string{"Invoice": {"id", "total"}}, "base.proto"))
+	if err != nil {
+		t.Fatalf("re-upload failed: %v", err)
+	}
+	if !reflect.DeepEqual(res.Changed, []string{"billing.Invoice"}) {
+		t.Errorf("Changed = %v, want [billing.Invoice]", res.Changed)
+	}
+
+	if _, err := reg.RemoveSet("billing"); err != nil {
+		t.Fatalf("RemoveSet failed: %v", err)
+	}
+	if _, err := reg.FormatJSON("billing.Invoice", nil); err == nil {
+		t.Errorf("removed type still resolvable")
+	}
+	if _, err := reg.RemoveSet("billing"); !errors.Is(err, registry.ErrSetNotFound) {
+		t.Errorf("second RemoveSet = %v, want ErrSetNotFound", err)
+	}
+}
+
+func stringPtr(s string) *string { return &s }
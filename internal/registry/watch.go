@@ -0,0 +1,159 @@
+/*******************************************************************************
+*  internal/registry/watch.go
+*
+*  Watches the registry's descriptor files and directories and reloads the
+*  registry when they change, so new message types can be decoded without
+*  restarting the collector.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// reloadDelay coalesces bursts of file events (e.g. a file written in
+// several chunks, or many files copied at once) into a single reload.
+const reloadDelay = 300 * time.Millisecond
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// Watch starts reloading the registry whenever one of its sources (or .proto
+// source directories) changes. Directories are watched recursively; for
+// files, the containing directory is watched so that atomic replacements
+// (rename over) are seen. Sources that do not exist yet are picked up when
+// created if their parent directory exists; if it does not, the source is
+// not watched and a warning is logged.
+func (r *Registry) Watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("create watcher: %w", err)
+	}
+
+	for _, src := range append(r.Sources(), r.protoDirs()...) {
+		if info, err := os.Stat(src); err == nil && info.IsDir() {
+			addTree(w, src)
+		} else if err := w.Add(filepath.Dir(src)); err != nil {
+			log.Printf("schema registry: cannot watch %q: %v", src, err)
+		}
+	}
+
+	r.watcher = w
+	go r.watchLoop(w)
+	return nil
+}
+
+// addTree watches dir and every directory below it.
+func addTree(w *fsnotify.Watcher, dir string) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			if err := w.Add(path); err != nil {
+				log.Printf("schema registry: cannot watch %q: %v", path, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *Registry) watchLoop(w *fsnotify.Watcher) {
+	var timer *time.Timer
+
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			}
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() &&
+					(r.isSource(ev.Name) || r.underDirSource(ev.Name) || r.underProtoDir(ev.Name)) {
+					addTree(w, ev.Name)
+				}
+			}
+			if !r.relevant(ev.Name) {
+				continue
+			}
+			if timer == nil {
+				timer = time.AfterFunc(reloadDelay, func() { r.Reload() })
+			} else {
+				timer.Reset(reloadDelay)
+			}
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("schema registry: watch error: %v", err)
+		}
+	}
+}
+
+// relevant reports whether a change to path affects the registry.
+func (r *Registry) relevant(path string) bool {
+	return r.isSource(path) ||
+		(filepath.Ext(path) == ".desc" && r.underDirSource(path)) ||
+		(filepath.Ext(path) == ".proto" && r.underProtoDir(path))
+}
+
+func (r *Registry) isSource(path string) bool {
+	for _, src := range r.sources {
+		if filepath.Clean(path) == filepath.Clean(src) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) underDirSource(path string) bool {
+	for _, src := range r.sources {
+		rel, err := filepath.Rel(src, path)
+		if err == nil && rel != "." && rel != ".." && !hasDotDotPrefix(rel) {
+			if info, err := os.Stat(src); err == nil && info.IsDir() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *Registry) underProtoDir(path string) bool {
+	for _, dir := range r.protoDirs() {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != "." && rel != ".." && !hasDotDotPrefix(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDotDotPrefix(rel string) bool {
+	return len(rel) >= 3 && rel[:3] == ".."+string(filepath.Separator)
+}
+
+// Close stops watching. The registry stays usable.
+func (r *Registry) Close() error {
+	if r == nil || r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
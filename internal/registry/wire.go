@@ -0,0 +1,268 @@
+/*******************************************************************************
+*  internal/registry/wire.go
+*
+*  Schema-less decoding. Payloads whose type is unknown (or that do not parse
+*  as their declared type) are walked at the wire-format level and rendered as
+*  a generic tree of field numbers and wire types. Length-delimited fields are
+*  guessed to be strings, nested messages or packed varints; the result is
+*  best-effort and flagged as heuristic. For payloads of a known type, every
+*  length-delimited value is blanked, since any of them may be a sensitive
+*  field.
+*******************************************************************************/
+
+package registry
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// maxWireDepth bounds how deep length-delimited fields are tried as nested
+// messages.
+const maxWireDepth = 32
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// WirePayload is the heuristic decoding of a payload.
+type WirePayload struct {
+	Heuristic bool        `json:"heuristic"` // always true
+	Fields    []WireField `json:"fields"`
+	Error     string      `json:"error,omitempty"`    // why decoding stopped early
+	Trailing  string      `json:"trailing,omitempty"` // hex of the undecodable rest
+}
+
+// WireField is one field as seen on the wire. Value holds the primary
+// interpretation; the other members are alternative readings of the same
+// bits.
+type WireField struct {
+	Number   int32  `json:"number"`
+	WireType string `json:"wire_type"` // varint, fixed32, fixed64, bytes or group
+
+	// As is the guess for a bytes field: string, message, packed or bytes.
+	As    string `json:"as,omitempty"`
+	Value any    `json:"value"`
+
+	Signed *int64   `json:"signed,omitempty"` // varint as two's complement, if negative
+	Zigzag *int64   `json:"zigzag,omitempty"` // varint as sint32/sint64
+	Float  *float64 `json:"float,omitempty"`  // fixed32 as float, if finite
+	Double *float64 `json:"double,omitempty"` // fixed64 as double, if finite
+}
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// DecodeWire decodes payload without a schema. It never fails: whatever
+// cannot be parsed is reported in Error and Trailing.
+func DecodeWire(payload []byte) WirePayload {
+	fields, n, err := decodeWireFields(payload, 0)
+	wp := WirePayload{Heuristic: true, Fields: fields}
+	if err != nil {
+		wp.Error = err.Error()
+		wp.Trailing = hex.EncodeToString(payload[n:])
+	}
+	return wp
+}
+
+// FormatWire is DecodeWire rendered as JSON, with the registry's redaction
+// patterns applied to every string that was recognized. Field-name rules and
+// the sensitive option cannot apply without a schema.
+func (r *Registry) FormatWire(payload []byte) ([]byte, error) {
+	wp := DecodeWire(payload)
+	if r != nil && r.redactor != nil {
+		redactWire(r.redactor, wp.Fields)
+	}
+	return json.Marshal(wp)
+}
+
+// formatWireBlanked is FormatWire for payloads whose type is known but that
+// do not decode as it. Schema-based redaction cannot tell which values are
+// sensitive, so every length-delimited leaf and the undecodable rest are
+// blanked.
+func formatWireBlanked(payload []byte) ([]byte, error) {
+	wp := DecodeWire(payload)
+	blankWire(wp.Fields)
+	if wp.Trailing != "" {
+		wp.Trailing = RedactedValue
+	}
+	return json.Marshal(wp)
+}
+
+// decodeWireFields decodes b as a sequence of fields, returning the fields
+// decoded and the number of bytes consumed before any error.
+func decodeWireFields(b []byte, depth int) ([]WireField, int, error) {
+	fields := []WireField{}
+	off := 0
+	for off < len(b) {
+		num, typ, n := protowire.ConsumeTag(b[off:])
+		if n < 0 {
+			return fields, off, fmt.Errorf("bad tag at offset %d: %w", off, protowire.ParseError(n))
+		}
+		if typ == protowire.EndGroupType {
+			return fields, off, fmt.Errorf("unexpected end-group at offset %d", off)
+		}
+
+		f, m, err := decodeWireValue(num, typ, b[off+n:], depth)
+		if err != nil {
+			return fields, off, fmt.Errorf("field %d at offset %d: %w", num, off, err)
+		}
+		fields = append(fields, f)
+		off += n + m
+	}
+	return fields, off, nil
+}
+
+func decodeWireValue(num protowire.Number, typ protowire.Type, b []byte, depth int) (WireField, int, error) {
+	f := WireField{Number: int32(num)}
+	switch typ {
+	case protowire.VarintType:
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return f, 0, protowire.ParseError(n)
+		}
+		f.WireType, f.Value = "varint", v
+		if s := int64(v); s < 0 {
+			f.Signed = &s
+		}
+		if z := protowire.DecodeZigZag(v); z < 0 {
+			f.Zigzag = &z
+		}
+		return f, n, nil
+
+	case protowire.Fixed32Type:
+		v, n := protowire.ConsumeFixed32(b)
+		if n < 0 {
+			return f, 0, protowire.ParseError(n)
+		}
+		f.WireType, f.Value = "fixed32", v
+		if fl := float64(math.Float32frombits(v)); !math.IsNaN(fl) && !math.IsInf(fl, 0) {
+			f.Float = &fl
+		}
+		return f, n, nil
+
+	case protowire.Fixed64Type:
+		v, n := protowire.ConsumeFixed64(b)
+		if n < 0 {
+			return f, 0, protowire.ParseError(n)
+		}
+		f.WireType, f.Value = "fixed64", v
+		if d := math.Float64frombits(v); !math.IsNaN(d) && !math.IsInf(d, 0) {
+			f.Double = &d
+		}
+		return f, n, nil
+
+	case protowire.BytesType:
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return f, 0, protowire.ParseError(n)
+		}
+		f.WireType = "bytes"
+		f.As, f.Value = guessBytes(v, depth)
+		return f, n, nil
+
+	case protowire.StartGroupType:
+		v, n := protowire.ConsumeGroup(num, b)
+		if n < 0 {
+			return f, 0, protowire.ParseError(n)
+		}
+		f.WireType = "group"
+		if depth >= maxWireDepth {
+			f.Value = hex.EncodeToString(v)
+			return f, n, nil
+		}
+		fields, _, err := decodeWireFields(v, depth+1)
+		if err != nil {
+			return f, 0, err
+		}
+		f.Value = fields
+		return f, n, nil
+	}
+	return f, 0, fmt.Errorf("unknown wire type %d", typ)
+}
+
+// guessBytes interprets a length-delimited value. Printable UTF-8 is taken
+// as a string first, since short strings often also parse as messages.
+func guessBytes(v []byte, depth int) (string, any) {
+	if len(v) == 0 {
+		return "string", ""
+	}
+	if printable(v) {
+		return "string", string(v)
+	}
+	if depth < maxWireDepth {
+		if fields, _, err := decodeWireFields(v, depth+1); err == nil {
+			return "message", fields
+		}
+	}
+	if packed, ok := packedVarints(v); ok {
+		return "packed", packed
+	}
+	return "bytes", v // base64 in JSON
+}
+
+func printable(v []byte) bool {
+	if !utf8.Valid(v) {
+		return false
+	}
+	for _, r := range string(v) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func packedVarints(v []byte) ([]uint64, bool) {
+	var out []uint64
+	for len(v) > 0 {
+		x, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return nil, false
+		}
+		out = append(out, x)
+		v = v[n:]
+	}
+	return out, true
+}
+
+// redactWire applies rd's patterns to the strings in fields.
+func redactWire(rd *Redactor, fields []WireField) {
+	for i := range fields {
+		switch v := fields[i].Value.(type) {
+		case string:
+			if fields[i].As == "string" {
+				fields[i].Value = rd.String(v)
+			}
+		case []WireField:
+			redactWire(rd, v)
+		}
+	}
+}
+
+// blankWire replaces every length-delimited leaf in fields (and group left
+// undecoded) with RedactedValue.
+func blankWire(fields []WireField) {
+	for i := range fields {
+		if v, ok := fields[i].Value.([]WireField); ok {
+			blankWire(v)
+		} else if fields[i].WireType == "bytes" || fields[i].WireType == "group" {
+			fields[i].Value = RedactedValue
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/Espeer5/protolog/internal/registry"
+)
+
+func TestDecodeWire(t *testing.T) {
+	var inner []byte
+	inner = protowire.AppendTag(inner, 1, protowire.VarintType)
+	inner = protowire.AppendVarint(inner, 7)
+	inner = protowire.AppendTag(inner, 2, protowire.Fixed64Type)
+	inner = protowire.AppendFixed64(inner, math.Float64bits(1.5))
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, "hello secret-123")
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(-3))
+	b = protowire.AppendTag(b, 3, protowire.BytesType)
+	b = protowire.AppendBytes(b, inner)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte{0x01, 0x02, 0xac, 0x02})
+	b = protowire.AppendTag(b, 5, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, math.Float32bits(2.5))
+
+	wp := registry.DecodeWire(b)
+	if !wp.Heuristic || wp.Error != "" || len(wp.Fields) != 5 {
+		t.Fatalf("DecodeWire = %+v", wp)
+	}
+	f := wp.Fields
+	if f[0].As != "string" || f[0].Value != "hello secret-123" {
+		t.Errorf("field 1 = %+v", f[0])
+	}
+	if f[1].WireType != "varint" || f[1].Zigzag == nil || *f[1].Zigzag != -3 {
+		t.Errorf("field 2 = %+v", f[1])
+	}
+	nested, ok := f[2].Value.([]registry.WireField)
+	if f[2].As != "message" || !ok || len(nested) != 2 || nested[1].Double == nil || *nested[1].Double != 1.5 {
+		t.Errorf("field 3 = %+v", f[2])
+	}
+	if f[3].As != "packed" || !reflect.DeepEqual(f[3].Value, []uint64{1, 2, 300}) {
+		t.Errorf("field 4 = %+v", f[3])
+	}
+	if f[4].WireType != "fixed32" || f[4].Float == nil || *f[4].Float != 2.5 {
+		t.Errorf("field 5 = %+v", f[4])
+	}
+
+	// Truncated input keeps what was decoded.
+	wp = registry.DecodeWire(append(b, 0x32, 0x10, 0x01))
+	if len(wp.Fields) != 5 || wp.Error == "" || wp.Trailing != "321001" {
+		t.Errorf("truncated: fields=%d error=%q trailing=%q", len(wp.Fields), wp.Error, wp.Trailing)
+	}
+
+	// FormatWire applies redaction patterns to recognized strings.
+	reg, _ := registry.Open(nil)
+	rd, err := registry.NewRedactor(registry.RedactionRules{Patterns: []string{`secret-\d+`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetRedactor(rd)
+	out, err := reg.FormatWire(b)
+	if err != nil {
+		t.Fatalf("FormatWire failed: %v", err)
+	}
+	var got struct {
+		Heuristic bool `json:"heuristic"`
+		Fields    []struct {
+			Value any `json:"value"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("FormatWire output is not JSON: %v", err)
+	}
+	if !got.Heuristic || got.Fields[0].Value != "hello "+registry.RedactedValue {
+		t.Errorf("FormatWire = %s", out)
+	}
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"database/sql"
+)
+
+// SchemaVersionRow is one stored version of a message type's descriptor.
+type SchemaVersionRow struct {
+	TypeName    string
+	Digest      string // hex digest of the message descriptor
+	ValidFromMs int64  // first event time the version applies to
+	Descriptors []byte // FileDescriptorSet with the type's file and its imports
+}
+
+// InsertSchemaVersion stores a new descriptor version.
+func InsertSchemaVersion(db *sql.DB, v SchemaVersionRow) error {
+	_, err := db.Exec(`
+		INSERT INTO schema_versions (type_name, digest, valid_from_ms, descriptors)
+		VALUES (?, ?, ?, ?)`,
+		v.TypeName, v.Digest, v.ValidFromMs, v.Descriptors,
+	)
+	return err
+}
+
+// LoadSchemaVersions returns every stored version ordered by type and
+// validity start.
+func LoadSchemaVersions(db *sql.DB) ([]SchemaVersionRow, error) {
+	rows, err := db.Query(`
+		SELECT type_name, digest, valid_from_ms, descriptors
+		FROM schema_versions
+		ORDER BY type_name, valid_from_ms, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SchemaVersionRow
+	for rows.Next() {
+		var v SchemaVersionRow
+		if err := rows.Scan(&v.TypeName, &v.Digest, &v.ValidFromMs, &v.Descriptors); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// SeriesValue is a numeric payload field materialized at ingest.
+type SeriesValue struct {
+	Field string // field path within the payload type, e.g. "value"
+	Value float64
+}
+
+// SeriesBucket aggregates the points of one time bucket.
+type SeriesBucket struct {
+	StartMs int64   `json:"start_ms"`
+	Count   int     `json:"count"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Avg     float64 `json:"avg"`
+	Last    float64 `json:"last"` // value with the latest timestamp
+
+	sum    float64
+	lastTS int64
+}
+
+// SeriesAggregator buckets points by time. Buckets are StartMs-aligned and
+// BucketMs wide; points outside [StartMs, EndMs) are ignored.
+type SeriesAggregator struct {
+	StartMs, EndMs, BucketMs int64
+
+	buckets map[int64]*SeriesBucket
+}
+
+// InsertSeriesValues stores the materialized values of the log row logID in
+// one transaction.
+func InsertSeriesValues(db *sql.DB, logID int64, values []SeriesValue) error {
+	if len(values) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, v := range values {
+		if _, err := tx.Exec(`
+			INSERT INTO series_values (log_id, field, value) VALUES (?, ?, ?)`,
+			logID, v.Field, v.Value,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QuerySeries aggregates the materialized values of field for the rows
+// matching f (cursor and limit are ignored) per bucketMs-wide time bucket
+// aligned to f.StartMs, in SQL. Empty buckets are omitted.
+func QuerySeries(db *sql.DB, field string, f LogFilter, bucketMs int64) ([]SeriesBucket, error) {
+	if bucketMs <= 0 {
+		return nil, fmt.Errorf("invalid bucket width %d", bucketMs)
+	}
+	where, args := f.whereClause()
+	where = append([]string{"series_values.field = ?"}, where...)
+	args = append([]any{f.StartMs, f.StartMs, bucketMs, bucketMs, field}, args...)
+
+	// rn = 1 marks the latest value of each bucket.
+	rows, err := db.Query(`
+SELECT bucket, COUNT(*), MIN(value), MAX(value), AVG(value),
+  MAX(CASE WHEN rn = 1 THEN value END)
+FROM (
+  SELECT bucket, value,
+    ROW_NUMBER() OVER (PARTITION BY bucket ORDER BY ts DESC, id DESC) AS rn
+  FROM (
+    SELECT
+      ? + (logs.event_ts_ms - ?) / ? * ? AS bucket,
+      logs.event_ts_ms AS ts, logs.id AS id, series_values.value AS value
+    FROM series_values
+    JOIN logs ON logs.id = series_values.log_id
+    WHERE `+strings.Join(where, "\n      AND ")+`
+  )
+)
+GROUP BY bucket
+ORDER BY bucket`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []SeriesBucket
+	for rows.Next() {
+		var b SeriesBucket
+		if err := rows.Scan(&b.StartMs, &b.Count, &b.Min, &b.Max, &b.Avg, &b.Last); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// Add adds a point.
+func (a *SeriesAggregator) Add(tsMs int64, v float64) {
+	if tsMs < a.StartMs || tsMs >= a.EndMs || a.BucketMs <= 0 || math.IsNaN(v) {
+		return
+	}
+	start := a.StartMs + (tsMs-a.StartMs)/a.BucketMs*a.BucketMs
+
+	if a.buckets == nil {
+		a.buckets = make(map[int64]*SeriesBucket)
+	}
+	b, ok := a.buckets[start]
+	if !ok {
+		b = &SeriesBucket{StartMs: start, Min: v, Max: v, Last: v, lastTS: tsMs}
+		a.buckets[start] = b
+	}
+	b.Count++
+	b.sum += v
+	b.Min = math.Min(b.Min, v)
+	b.Max = math.Max(b.Max, v)
+	if tsMs >= b.lastTS {
+		b.Last, b.lastTS = v, tsMs
+	}
+}
+
+// Buckets returns the non-empty buckets in time order.
+func (a *SeriesAggregator) Buckets() []SeriesBucket {
+	out := make([]SeriesBucket, 0, len(a.buckets))
+	for _, b := range a.buckets {
+		b.Avg = b.sum / float64(b.Count)
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartMs < out[j].StartMs })
+	return out
+}
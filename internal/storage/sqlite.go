@@ -2,8 +2,10 @@ package storage
 
 import (
 	"database/sql"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -54,9 +56,13 @@ func InitSchema(db *sql.DB) error {
 		summary        TEXT,
 		session_id     TEXT,
 		correlation_id TEXT,
-		payload        BLOB
+		payload        BLOB,
+		client_id      TEXT
 	);
 
+	CREATE INDEX IF NOT EXISTS idx_logs_topic_event
+		ON logs(topic, event_ts_ms, id);
+
 	CREATE INDEX IF NOT EXISTS idx_logs_service_event
 		ON logs(service, event_ts_ms, id);
 
@@ -68,8 +74,69 @@ func InitSchema(db *sql.DB) error {
 
 	CREATE INDEX IF NOT EXISTS idx_logs_level_event
 		ON logs(level, event_ts_ms, id);
+
+	CREATE TABLE IF NOT EXISTS schema_versions (
+		id            INTEGER PRIMARY KEY AUTOINCREMENT,
+		type_name     TEXT NOT NULL,
+		digest        TEXT NOT NULL,
+		valid_from_ms INTEGER NOT NULL,
+		descriptors   BLOB NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_schema_versions_type
+		ON schema_versions(type_name, valid_from_ms);
+
+	CREATE TABLE IF NOT EXISTS series_values (
+		log_id INTEGER NOT NULL,
+		field  TEXT NOT NULL,
+		value  REAL NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_series_values_field
+		ON series_values(field, log_id);
+
+	CREATE INDEX IF NOT EXISTS idx_series_values_log
+		ON series_values(log_id);
+
+	-- Series values go with their log row (a trigger rather than a foreign
+	-- key, which would need PRAGMA foreign_keys on every connection).
+	CREATE TRIGGER IF NOT EXISTS trg_logs_delete_series
+		AFTER DELETE ON logs
+	BEGIN
+		DELETE FROM series_values WHERE log_id = OLD.id;
+	END;
 	`
-	_, err := db.Exec(schema)
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema; CREATE TABLE IF NOT EXISTS
+	// leaves existing databases untouched, so add them here.
+	return ensureColumn(db, "logs", "client_id", "TEXT")
+}
+
+// ensureColumn adds a column to table if it does not exist yet.
+func ensureColumn(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + decl)
 	return err
 }
 
@@ -96,7 +163,19 @@ func nullBlob(b []byte) any {
 
 // InsertLog stores the envelope metadata + the raw payload bytes (nullable).
 func InsertLog(db *sql.DB, env *logging.LogEnvelope) error {
-	_, err := db.Exec(`
+	return InsertLogFrom(db, env, "")
+}
+
+// InsertLogFrom is InsertLog for envelopes received from an authenticated
+// client; clientID is stored alongside the row (NULL if empty).
+func InsertLogFrom(db *sql.DB, env *logging.LogEnvelope, clientID string) error {
+	_, err := InsertLogFromID(db, env, clientID)
+	return err
+}
+
+// InsertLogFromID is InsertLogFrom, returning the id of the new row.
+func InsertLogFromID(db *sql.DB, env *logging.LogEnvelope, clientID string) (int64, error) {
+	res, err := db.Exec(`
 		INSERT INTO logs (
 			event_ts_ms,
 			ingest_ts_ms,
@@ -109,8 +188,9 @@ func InsertLog(db *sql.DB, env *logging.LogEnvelope) error {
 			summary,
 			session_id,
 			correlation_id,
-			payload
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			payload,
+			client_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
 		tsToMillis(env.Timestamp),
 		time.Now().UnixMilli(),
@@ -124,8 +204,12 @@ func InsertLog(db *sql.DB, env *logging.LogEnvelope) error {
 		nullString(env.SessionId),
 		nullString(env.CorrelationId),
 		nullBlob(env.Payload),
+		nullString(clientID),
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
 }
 
 type LogRow struct {
@@ -139,6 +223,7 @@ type LogRow struct {
 	Host      sql.NullString
 	Pid       sql.NullInt64
 	Payload   []byte // nil if NULL
+	ClientID  sql.NullString
 }
 
 func QueryLogs(db *sql.DB,
@@ -153,7 +238,7 @@ func QueryLogs(db *sql.DB,
 			id, event_ts_ms,
 			topic, service, level,
 			summary, type, host, pid,
-			payload
+			payload, client_id
 		FROM logs
 		WHERE event_ts_ms >= ?
 		  AND event_ts_ms < ?
@@ -169,7 +254,7 @@ func QueryLogs(db *sql.DB,
 		startMs, endMs,
 		topic, topic,
 		service, service,
-		cursorTS,
+		cursorID,
 		cursorTS, cursorTS, cursorID,
 		limit,
 	)
@@ -187,7 +272,7 @@ func QueryLogs(db *sql.DB,
 			&r.ID, &r.EventTSMs,
 			&r.Topic, &r.Service, &r.Level,
 			&r.Summary, &r.Type, &r.Host, &r.Pid,
-			&payload,
+			&payload, &r.ClientID,
 		); err != nil {
 			return nil, err
 		}
@@ -203,8 +288,40 @@ func QueryLogs(db *sql.DB,
 	return out, rows.Err()
 }
 
+// Grant limits a query to rows whose topic matches one of Topics and whose
+// service matches one of Services. Patterns use SQLite GLOB syntax; an empty
+// list matches anything.
+type Grant struct {
+	Topics   []string
+	Services []string
+}
+
+// LogFilter selects rows for QueryLogsFiltered. Empty slices do not filter.
+type LogFilter struct {
+	StartMs, EndMs int64
+	Topics         []string
+	Services       []string
+	Hosts          []string
+	Levels         []int
+	Types          []string
+
+	// Grants restricts the result to rows matched by at least one grant.
+	// nil means unrestricted; a non-nil empty slice matches no rows.
+	Grants []Grant
+
+	// CursorID, if not 0, pages from the row (CursorTS, CursorID): the
+	// rows after it in event order or, with Before, the rows before it.
+	// Row ids start at 1, so any timestamp, including 0, is a valid cursor.
+	CursorTS, CursorID int64
+	Before             bool
+
+	// Desc returns rows newest first instead of oldest first.
+	Desc  bool
+	Limit int
+}
+
 // QueryLogsMulti queries by time range + optional multi-value filters.
-// Cursor is (event_ts_ms, id) for stable paging.
+// Cursor is (event_ts_ms, id) for stable paging; cursorID 0 means none.
 func QueryLogsMulti(
 	db *sql.DB,
 	startMs, endMs int64,
@@ -216,50 +333,96 @@ func QueryLogsMulti(
 	cursorTS, cursorID int64,
 	limit int,
 ) ([]LogRow, error) {
-	where := []string{
-		"event_ts_ms >= ?",
-		"event_ts_ms < ?",
+	return QueryLogsFiltered(db, LogFilter{
+		StartMs:  startMs,
+		EndMs:    endMs,
+		Topics:   topics,
+		Services: services,
+		Hosts:    hosts,
+		Levels:   levels,
+		Types:    types,
+		CursorTS: cursorTS,
+		CursorID: cursorID,
+		Limit:    limit,
+	})
+}
+
+// QueryLogsFiltered is QueryLogsMulti with the filter passed as a struct,
+// including access grants, cursor direction and order.
+func QueryLogsFiltered(db *sql.DB, f LogFilter) ([]LogRow, error) {
+	where, args := f.whereClause()
+	return queryPage(db, where, args, f)
+}
+
+// LogByID returns the row id if grants (see LogFilter.Grants) allow it, or
+// sql.ErrNoRows.
+func LogByID(db *sql.DB, id int64, grants []Grant) (LogRow, error) {
+	where, args := LogFilter{StartMs: math.MinInt64, EndMs: math.MaxInt64, Grants: grants}.whereClause()
+	where = append(where, "id = ?")
+	args = append(args, id)
+
+	rows, err := queryPage(db, where, args, LogFilter{Limit: 1})
+	if err != nil {
+		return LogRow{}, err
+	}
+	if len(rows) == 0 {
+		return LogRow{}, sql.ErrNoRows
 	}
-	args := []any{startMs, endMs}
+	return rows[0], nil
+}
 
-	// helper to build "col IN (?, ?, ?)"
-	addInStrings := func(col string, vals []string) {
-		if len(vals) == 0 {
-			return
-		}
-		ph := make([]string, 0, len(vals))
-		for range vals {
-			ph = append(ph, "?")
-		}
-		where = append(where, col+" IN ("+strings.Join(ph, ",")+")")
-		for _, v := range vals {
-			args = append(args, v)
-		}
+// QueryLogContext returns up to before rows preceding and after rows
+// following the row id in event order, from the same host and service,
+// like grep -C. The rows are in event order, the row id itself at index
+// i. Grants apply to every row; if the row id is not allowed the error is
+// sql.ErrNoRows.
+func QueryLogContext(db *sql.DB, id int64, before, after int, grants []Grant) (rows []LogRow, i int, err error) {
+	anchor, err := LogByID(db, id, grants)
+	if err != nil {
+		return nil, 0, err
 	}
-	addInInts := func(col string, vals []int) {
-		if len(vals) == 0 {
-			return
-		}
-		ph := make([]string, 0, len(vals))
-		for range vals {
-			ph = append(ph, "?")
-		}
-		where = append(where, col+" IN ("+strings.Join(ph, ",")+")")
-		for _, v := range vals {
-			args = append(args, v)
-		}
+
+	// IS matches NULL hosts and services too.
+	where, args := LogFilter{StartMs: math.MinInt64, EndMs: math.MaxInt64, Grants: grants}.whereClause()
+	where = append(where, "host IS ?", "service IS ?")
+	args = append(args, anchor.Host, anchor.Service)
+
+	page := LogFilter{CursorTS: anchor.EventTSMs, CursorID: anchor.ID, Before: true, Limit: before}
+	prev, err := queryPage(db, where, args, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	page.Before, page.Limit = false, after
+	next, err := queryPage(db, where, args, page)
+	if err != nil {
+		return nil, 0, err
 	}
 
-	addInStrings("topic", topics)
-	addInStrings("service", services)
-	addInStrings("host", hosts)
-	addInStrings("type", types)
-	addInInts("level", levels)
+	rows = append(append(prev, anchor), next...)
+	return rows, len(prev), nil
+}
 
-	// cursor paging
-	if cursorTS != 0 {
-		where = append(where, "(event_ts_ms > ? OR (event_ts_ms = ? AND id > ?))")
-		args = append(args, cursorTS, cursorTS, cursorID)
+// queryPage selects up to f.Limit rows matching where, starting at the
+// cursor of f and in the order of f.
+func queryPage(db *sql.DB, where []string, args []any, f LogFilter) ([]LogRow, error) {
+	where = slices.Clip(where)
+	args = slices.Clip(args)
+	limit := f.Limit
+
+	// Scan away from the cursor; without one, scan in the requested order.
+	desc := f.Desc
+	if f.CursorID != 0 {
+		desc = f.Before
+		if f.Before {
+			where = append(where, "(event_ts_ms < ? OR (event_ts_ms = ? AND id < ?))")
+		} else {
+			where = append(where, "(event_ts_ms > ? OR (event_ts_ms = ? AND id > ?))")
+		}
+		args = append(args, f.CursorTS, f.CursorTS, f.CursorID)
+	}
+	order := "ORDER BY event_ts_ms ASC, id ASC"
+	if desc {
+		order = "ORDER BY event_ts_ms DESC, id DESC"
 	}
 
 	args = append(args, limit)
@@ -269,10 +432,10 @@ SELECT
   id, event_ts_ms,
   topic, service, level,
   summary, type, host, pid,
-  payload
+  payload, client_id
 FROM logs
 WHERE ` + strings.Join(where, "\n  AND ") + `
-ORDER BY event_ts_ms ASC, id ASC
+` + order + `
 LIMIT ?`
 
 	rows, err := db.Query(q, args...)
@@ -289,7 +452,7 @@ LIMIT ?`
 			&r.ID, &r.EventTSMs,
 			&r.Topic, &r.Service, &r.Level,
 			&r.Summary, &r.Type, &r.Host, &r.Pid,
-			&payload,
+			&payload, &r.ClientID,
 		); err != nil {
 			return nil, err
 		}
@@ -298,6 +461,84 @@ LIMIT ?`
 		}
 		out = append(out, r)
 	}
-	return out, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// A page read against the requested order is flipped back.
+	if desc != f.Desc {
+		slices.Reverse(out)
+	}
+	return out, nil
+}
+
+// whereClause renders every condition of f except the cursor.
+func (f LogFilter) whereClause() ([]string, []any) {
+	where := []string{
+		"event_ts_ms >= ?",
+		"event_ts_ms < ?",
+	}
+	args := []any{f.StartMs, f.EndMs}
+
+	// helper to build "col IN (?, ?, ?)"
+	addIn := func(col string, n int, arg func(i int) any) {
+		if n == 0 {
+			return
+		}
+		ph := make([]string, n)
+		for i := range ph {
+			ph[i] = "?"
+			args = append(args, arg(i))
+		}
+		where = append(where, col+" IN ("+strings.Join(ph, ",")+")")
+	}
+
+	addIn("topic", len(f.Topics), func(i int) any { return f.Topics[i] })
+	addIn("service", len(f.Services), func(i int) any { return f.Services[i] })
+	addIn("host", len(f.Hosts), func(i int) any { return f.Hosts[i] })
+	addIn("type", len(f.Types), func(i int) any { return f.Types[i] })
+	addIn("level", len(f.Levels), func(i int) any { return f.Levels[i] })
+
+	if f.Grants != nil {
+		where = append(where, grantClause(f.Grants, &args))
+	}
+
+	return where, args
 }
 
+// grantClause renders grants as an OR of GLOB conditions.
+func grantClause(grants []Grant, args *[]any) string {
+	if len(grants) == 0 {
+		return "0"
+	}
+	for _, g := range grants {
+		if len(g.Topics) == 0 && len(g.Services) == 0 {
+			return "1"
+		}
+	}
+
+	globAny := func(col string, patterns []string) string {
+		if len(patterns) == 0 {
+			return ""
+		}
+		parts := make([]string, len(patterns))
+		for i, p := range patterns {
+			parts[i] = col + " GLOB ?"
+			*args = append(*args, p)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")"
+	}
+
+	ors := make([]string, 0, len(grants))
+	for _, g := range grants {
+		var ands []string
+		if c := globAny("topic", g.Topics); c != "" {
+			ands = append(ands, c)
+		}
+		if c := globAny("COALESCE(service, '')", g.Services); c != "" {
+			ands = append(ands, c)
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
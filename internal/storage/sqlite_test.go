@@ -0,0 +1,402 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+// helper to open a fresh database with the schema applied
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	if err := InitSchema(db); err != nil {
+		t.Fatalf("InitSchema failed: %v", err)
+	}
+	return db
+}
+
+func TestInitSchema_MigratesClientID(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "old.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite failed: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	// Schema as created by earlier versions, without client_id.
+	if _, err := db.Exec(`CREATE TABLE logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, event_ts_ms INTEGER NOT NULL,
+		ingest_ts_ms INTEGER NOT NULL, topic TEXT NOT NULL, level INTEGER NOT NULL,
+		host TEXT, service TEXT, pid INTEGER, type TEXT, summary TEXT,
+		session_id TEXT, correlation_id TEXT, payload BLOB)`); err != nil {
+		t.Fatalf("create old schema: %v", err)
+	}
+
+	// Running InitSchema twice must be idempotent.
+	for i := 0; i < 2; i++ {
+		if err := InitSchema(db); err != nil {
+			t.Fatalf("InitSchema #%d failed: %v", i+1, err)
+		}
+	}
+
+	if err := InsertLogFrom(db, &logging.LogEnvelope{Topic: "t"}, "gw-01"); err != nil {
+		t.Fatalf("InsertLogFrom after migration failed: %v", err)
+	}
+}
+
+func TestInsertLogFrom_RecordsClientID(t *testing.T) {
+	db := openTestDB(t)
+
+	ts := timestamppb.New(time.UnixMilli(1_000))
+	if err := InsertLogFrom(db, &logging.LogEnvelope{Topic: "a", Timestamp: ts}, "sensor-gw"); err != nil {
+		t.Fatalf("InsertLogFrom failed: %v", err)
+	}
+	if err := InsertLog(db, &logging.LogEnvelope{Topic: "a", Timestamp: ts}); err != nil {
+		t.Fatalf("InsertLog failed: %v", err)
+	}
+
+	rows, err := QueryLogsMulti(db, 0, 10_000, nil, nil, nil, nil, nil, 0, 0, 10)
+	if err != nil {
+		t.Fatalf("QueryLogsMulti failed: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if !rows[0].ClientID.Valid || rows[0].ClientID.String != "sensor-gw" {
+		t.Errorf("row 0 ClientID = %+v, want sensor-gw", rows[0].ClientID)
+	}
+	if rows[1].ClientID.Valid {
+		t.Errorf("row 1 ClientID = %+v, want NULL", rows[1].ClientID)
+	}
+}
+
+func TestQueryLogsFiltered_Grants(t *testing.T) {
+	db := openTestDB(t)
+
+	ts := timestamppb.New(time.UnixMilli(1_000))
+	for _, e := range []*logging.LogEnvelope{
+		{Topic: "audit.login", Service: "auth", Timestamp: ts},
+		{Topic: "payments.card", Service: "billing", Timestamp: ts},
+		{Topic: "http.access", Service: "api-gw", Timestamp: ts},
+		{Topic: "http.access", Timestamp: ts},
+	} {
+		if err := InsertLog(db, e); err != nil {
+			t.Fatalf("InsertLog failed: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		grants []Grant
+		want   int
+	}{
+		{"unrestricted", nil, 4},
+		{"no grants", []Grant{}, 0},
+		{"topic pattern", []Grant{{Topics: []string{"payments.*"}}}, 1},
+		{"topic and service", []Grant{{Topics: []string{"http.*"}, Services: []string{"api-*"}}}, 1},
+		{"union", []Grant{{Topics: []string{"payments.*"}}, {Topics: []string{"http.*"}}}, 3},
+		{"catch-all grant", []Grant{{Topics: []string{"nothing"}}, {}}, 4},
+	}
+	for _, tc := range cases {
+		rows, err := QueryLogsFiltered(db, LogFilter{EndMs: 10_000, Grants: tc.grants, Limit: 10})
+		if err != nil {
+			t.Fatalf("%s: QueryLogsFiltered failed: %v", tc.name, err)
+		}
+		if len(rows) != tc.want {
+			t.Errorf("%s: got %d rows, want %d", tc.name, len(rows), tc.want)
+		}
+	}
+}
+
+func TestSchemaVersions_RoundTrip(t *testing.T) {
+	db := openTestDB(t)
+
+	in := []SchemaVersionRow{
+		{TypeName: "demo.Metric", Digest: "bb", ValidFromMs: 2_000, Descriptors: []byte{2}},
+		{TypeName: "demo.Metric", Digest: "aa", ValidFromMs: 0, Descriptors: []byte{1}},
+		{TypeName: "demo.Alert", Digest: "cc", ValidFromMs: 0, Descriptors: []byte{3}},
+	}
+	for _, v := range in {
+		if err := InsertSchemaVersion(db, v); err != nil {
+			t.Fatalf("InsertSchemaVersion failed: %v", err)
+		}
+	}
+
+	got, err := LoadSchemaVersions(db)
+	if err != nil {
+		t.Fatalf("LoadSchemaVersions failed: %v", err)
+	}
+	want := []string{"demo.Alert/cc", "demo.Metric/aa", "demo.Metric/bb"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d versions, want %d", len(got), len(want))
+	}
+	for i, v := range got {
+		if v.TypeName+"/"+v.Digest != want[i] {
+			t.Errorf("version %d = %s/%s, want %s", i, v.TypeName, v.Digest, want[i])
+		}
+	}
+}
+
+func TestSeries_MaterializedAndBucketed(t *testing.T) {
+	db := openTestDB(t)
+
+	// Two services report demo.Metric values; bucket width 1s.
+	for i, v := range []float64{5, 1, 3, 10, 7} {
+		service := "a"
+		if i == 3 {
+			service = "b"
+		}
+		ts := timestamppb.New(time.UnixMilli(int64(500 * i)))
+		id, err := InsertLogFromID(db, &logging.LogEnvelope{
+			Topic: "metrics", Service: service, Type: "demo.Metric", Timestamp: ts,
+		}, "")
+		if err != nil {
+			t.Fatalf("InsertLogFromID failed: %v", err)
+		}
+		if err := InsertSeriesValues(db, id, []SeriesValue{{Field: "value", Value: v}}); err != nil {
+			t.Fatalf("InsertSeriesValues failed: %v", err)
+		}
+	}
+
+	got, err := QuerySeries(db, "value", LogFilter{
+		EndMs: 10_000, Types: []string{"demo.Metric"}, Services: []string{"a"},
+	}, 1_000)
+	if err != nil {
+		t.Fatalf("QuerySeries failed: %v", err)
+	}
+	want := []SeriesBucket{
+		{StartMs: 0, Count: 2, Min: 1, Max: 5, Avg: 3, Last: 1},
+		{StartMs: 1_000, Count: 1, Min: 3, Max: 3, Avg: 3, Last: 3},
+		{StartMs: 2_000, Count: 1, Min: 7, Max: 7, Avg: 7, Last: 7},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d buckets, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g := got[i]
+		if g.StartMs != want[i].StartMs || g.Count != want[i].Count || g.Min != want[i].Min ||
+			g.Max != want[i].Max || g.Avg != want[i].Avg || g.Last != want[i].Last {
+			t.Errorf("bucket %d = %+v, want %+v", i, g, want[i])
+		}
+	}
+
+	// Deleting log rows deletes their series values.
+	if _, err := db.Exec(`DELETE FROM logs WHERE service = 'a'`); err != nil {
+		t.Fatalf("delete logs: %v", err)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM series_values`).Scan(&n); err != nil || n != 1 {
+		t.Errorf("series values after delete = %d, %v; want 1", n, err)
+	}
+
+	// A batch that fails part way stores nothing.
+	if err := InsertSeriesValues(db, 99, []SeriesValue{
+		{Field: "value", Value: 1}, {Field: "value", Value: math.NaN()},
+	}); err == nil {
+		t.Errorf("InsertSeriesValues accepted NaN")
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM series_values WHERE log_id = 99`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("series values after failed batch = %d, %v; want 0", n, err)
+	}
+}
+
+func TestHistogramAndGroupBy(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, e := range []struct {
+		ms      int64
+		service string
+		level   logging.LogLevel
+	}{
+		{0, "auth", logging.LogLevel_LOG_LEVEL_ERROR},
+		{30_000, "auth", logging.LogLevel_LOG_LEVEL_ERROR},
+		{45_000, "billing", logging.LogLevel_LOG_LEVEL_ERROR},
+		{70_000, "auth", logging.LogLevel_LOG_LEVEL_ERROR},
+		{80_000, "auth", logging.LogLevel_LOG_LEVEL_INFO},
+		{90_000, "", logging.LogLevel_LOG_LEVEL_ERROR},
+	} {
+		env := &logging.LogEnvelope{
+			Topic: "t", Service: e.service, Level: e.level, Timestamp: timestamppb.New(time.UnixMilli(e.ms)),
+		}
+		if err := InsertLog(db, env); err != nil {
+			t.Fatalf("InsertLog failed: %v", err)
+		}
+	}
+	errorsOnly := LogFilter{EndMs: 120_000, Levels: []int{int(logging.LogLevel_LOG_LEVEL_ERROR)}}
+
+	buckets, err := Histogram(db, errorsOnly, 60_000, "service")
+	if err != nil {
+		t.Fatalf("Histogram failed: %v", err)
+	}
+	want := []HistogramBucket{
+		{StartMs: 0, Group: "auth", Count: 2},
+		{StartMs: 0, Group: "billing", Count: 1},
+		{StartMs: 60_000, Group: "", Count: 1},
+		{StartMs: 60_000, Group: "auth", Count: 1},
+	}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("Histogram = %+v, want %+v", buckets, want)
+	}
+
+	groups, err := GroupBy(db, LogFilter{EndMs: 120_000}, []string{"service", "level"})
+	if err != nil {
+		t.Fatalf("GroupBy failed: %v", err)
+	}
+	if len(groups) != 4 {
+		t.Fatalf("got %d groups, want 4: %+v", len(groups), groups)
+	}
+	top := groups[0]
+	wantTop := map[string]string{"service": "auth", "level": strconv.Itoa(int(logging.LogLevel_LOG_LEVEL_ERROR))}
+	if !reflect.DeepEqual(top.Groups, wantTop) || top.Count != 3 || top.FirstMs != 0 || top.LastMs != 70_000 {
+		t.Errorf("top group = %+v, want auth/ERROR x3 over [0, 70000]", top)
+	}
+
+	if _, err := GroupBy(db, LogFilter{EndMs: 1}, []string{"payload"}); err == nil {
+		t.Errorf("GroupBy(payload) succeeded, want error")
+	}
+}
+
+func TestFacets(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, e := range []*logging.LogEnvelope{
+		{Topic: "http.access", Service: "api-gw", Host: "web-1", Type: "demo.Message"},
+		{Topic: "http.access", Service: "api-gw", Host: "web-2"},
+		{Topic: "audit.login", Service: "auth", Level: logging.LogLevel_LOG_LEVEL_ERROR},
+	} {
+		e.Timestamp = timestamppb.New(time.UnixMilli(1_000))
+		if err := InsertLog(db, e); err != nil {
+			t.Fatalf("InsertLog failed: %v", err)
+		}
+	}
+	// Outside the range.
+	if err := InsertLog(db, &logging.LogEnvelope{Topic: "old", Timestamp: timestamppb.New(time.UnixMilli(50_000))}); err != nil {
+		t.Fatalf("InsertLog failed: %v", err)
+	}
+
+	got, err := Facets(db, LogFilter{EndMs: 10_000}, []string{"topic", "host", "type", "level"})
+	if err != nil {
+		t.Fatalf("Facets failed: %v", err)
+	}
+	want := map[string][]FacetValue{
+		"topic": {{"http.access", 2}, {"audit.login", 1}},
+		"host":  {{"web-1", 1}, {"web-2", 1}},
+		"type":  {{"demo.Message", 1}},
+		"level": {
+			{strconv.Itoa(int(logging.LogLevel_LOG_LEVEL_UNSPECIFIED)), 2},
+			{strconv.Itoa(int(logging.LogLevel_LOG_LEVEL_ERROR)), 1},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Facets = %+v, want %+v", got, want)
+	}
+
+	// Empty values, here the most frequent type, do not take up the limit.
+	got, err = Facets(db, LogFilter{EndMs: 10_000, Limit: 1}, []string{"type"})
+	if err != nil {
+		t.Fatalf("Facets failed: %v", err)
+	}
+	if want := []FacetValue{{"demo.Message", 1}}; !reflect.DeepEqual(got["type"], want) {
+		t.Errorf("Facets(limit 1) type = %+v, want %+v", got["type"], want)
+	}
+}
+
+func TestQueryLogsFiltered_Paging(t *testing.T) {
+	db := openTestDB(t)
+
+	// Rows 1..5 at timestamps 0, 0, 1, 2, 2.
+	for _, ms := range []int64{0, 0, 1, 2, 2} {
+		if err := InsertLog(db, &logging.LogEnvelope{Topic: "a", Timestamp: timestamppb.New(time.UnixMilli(ms))}); err != nil {
+			t.Fatalf("InsertLog failed: %v", err)
+		}
+	}
+
+	ids := func(f LogFilter) []int64 {
+		t.Helper()
+		f.EndMs, f.Limit = 10, 2
+		rows, err := QueryLogsFiltered(db, f)
+		if err != nil {
+			t.Fatalf("QueryLogsFiltered failed: %v", err)
+		}
+		var out []int64
+		for _, r := range rows {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		name string
+		f    LogFilter
+		want []int64
+	}{
+		{"first", LogFilter{}, []int64{1, 2}},
+		{"after timestamp-0 cursor", LogFilter{CursorTS: 0, CursorID: 1}, []int64{2, 3}},
+		{"before", LogFilter{CursorTS: 2, CursorID: 4, Before: true}, []int64{2, 3}},
+		{"desc first", LogFilter{Desc: true}, []int64{5, 4}},
+		{"desc before", LogFilter{CursorTS: 1, CursorID: 3, Before: true, Desc: true}, []int64{2, 1}},
+		{"desc after", LogFilter{CursorTS: 0, CursorID: 1, Desc: true}, []int64{3, 2}},
+	} {
+		if got := ids(tc.f); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: ids = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// The legacy query honours a timestamp-0 cursor too.
+	rows, err := QueryLogs(db, 0, 10, "", "", 0, 2, 10)
+	if err != nil || len(rows) != 3 {
+		t.Errorf("QueryLogs after (0, 2) = %d rows, %v; want 3", len(rows), err)
+	}
+}
+
+func TestQueryLogContext(t *testing.T) {
+	db := openTestDB(t)
+
+	// Rows 1..7; web-1/api rows are 1, 3, 4, 6 and 7.
+	for i, e := range []*logging.LogEnvelope{
+		{Host: "web-1", Service: "api", Topic: "a"},
+		{Host: "web-2", Service: "api", Topic: "a"},
+		{Host: "web-1", Service: "api", Topic: "a"},
+		{Host: "web-1", Service: "api", Topic: "secret"},
+		{Host: "web-1", Service: "auth", Topic: "a"},
+		{Host: "web-1", Service: "api", Topic: "a"},
+		{Host: "web-1", Service: "api", Topic: "a"},
+	} {
+		e.Timestamp = timestamppb.New(time.UnixMilli(int64(i)))
+		if err := InsertLog(db, e); err != nil {
+			t.Fatalf("InsertLog failed: %v", err)
+		}
+	}
+
+	grants := []Grant{{Topics: []string{"a"}}}
+	rows, i, err := QueryLogContext(db, 6, 2, 5, grants)
+	if err != nil {
+		t.Fatalf("QueryLogContext failed: %v", err)
+	}
+	var got []int64
+	for _, r := range rows {
+		got = append(got, r.ID)
+	}
+	if want := []int64{1, 3, 6, 7}; !reflect.DeepEqual(got, want) || i != 2 {
+		t.Errorf("QueryLogContext = %v at %d, want %v at 2", got, i, want)
+	}
+
+	if _, _, err := QueryLogContext(db, 4, 2, 2, grants); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("QueryLogContext(denied row) error = %v, want sql.ErrNoRows", err)
+	}
+}
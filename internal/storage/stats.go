@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// groupColumns maps the dimensions logs can be grouped by to SQL
+// expressions. Missing values group as "".
+var groupColumns = map[string]string{
+	"topic":   "topic",
+	"service": "COALESCE(service, '')",
+	"host":    "COALESCE(host, '')",
+	"type":    "COALESCE(type, '')",
+	"level":   "CAST(level AS TEXT)",
+}
+
+// HistogramBucket counts the rows of one time bucket and group.
+type HistogramBucket struct {
+	StartMs int64  `json:"start_ms"`
+	Group   string `json:"group,omitempty"`
+	Count   int64  `json:"count"`
+}
+
+// GroupCount counts the rows sharing one combination of group values.
+type GroupCount struct {
+	Groups  map[string]string `json:"groups"`
+	Count   int64             `json:"count"`
+	FirstMs int64             `json:"first_ms"`
+	LastMs  int64             `json:"last_ms"`
+}
+
+// IsGroupColumn reports whether rows can be grouped by name (topic,
+// service, host, type or level).
+func IsGroupColumn(name string) bool {
+	_, ok := groupColumns[name]
+	return ok
+}
+
+// Histogram counts the rows matching f (cursor and limit are ignored) per
+// bucketMs-wide time bucket aligned to f.StartMs and, if groupBy is not
+// empty, per value of that column. Empty buckets are omitted.
+func Histogram(db *sql.DB, f LogFilter, bucketMs int64, groupBy string) ([]HistogramBucket, error) {
+	if bucketMs <= 0 {
+		return nil, fmt.Errorf("invalid bucket width %d", bucketMs)
+	}
+	group := "''"
+	if groupBy != "" {
+		var ok bool
+		if group, ok = groupColumns[groupBy]; !ok {
+			return nil, fmt.Errorf("cannot group by %q", groupBy)
+		}
+	}
+
+	// bucket start = StartMs + (ts - StartMs) / width * width
+	where, args := f.whereClause()
+	args = append([]any{f.StartMs, f.StartMs, bucketMs, bucketMs}, args...)
+
+	rows, err := db.Query(`
+SELECT
+  ? + (event_ts_ms - ?) / ? * ? AS bucket,
+  `+group+` AS grp,
+  COUNT(*)
+FROM logs
+WHERE `+strings.Join(where, "\n  AND ")+`
+GROUP BY bucket, grp
+ORDER BY bucket, grp`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []HistogramBucket
+	for rows.Next() {
+		var b HistogramBucket
+		if err := rows.Scan(&b.StartMs, &b.Group, &b.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// GroupBy counts the rows matching f (cursor ignored) per combination of
+// the values of the by columns, most frequent first. f.Limit, if positive,
+// bounds the number of groups returned.
+func GroupBy(db *sql.DB, f LogFilter, by []string) ([]GroupCount, error) {
+	if len(by) == 0 {
+		return nil, fmt.Errorf("no group columns")
+	}
+	cols := make([]string, len(by))
+	for i, name := range by {
+		col, ok := groupColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot group by %q", name)
+		}
+		cols[i] = col
+	}
+
+	where, args := f.whereClause()
+	q := `
+SELECT
+  ` + strings.Join(cols, ",\n  ") + `,
+  COUNT(*) AS n, MIN(event_ts_ms), MAX(event_ts_ms)
+FROM logs
+WHERE ` + strings.Join(where, "\n  AND ") + `
+GROUP BY ` + strings.Join(cols, ", ") + `
+ORDER BY n DESC, ` + strings.Join(cols, ", ")
+	if f.Limit > 0 {
+		q += "\nLIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []GroupCount
+	values := make([]string, len(by))
+	dest := make([]any, 0, len(by)+3)
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	for rows.Next() {
+		var g GroupCount
+		if err := rows.Scan(append(dest, &g.Count, &g.FirstMs, &g.LastMs)...); err != nil {
+			return nil, err
+		}
+		g.Groups = make(map[string]string, len(by))
+		for i, name := range by {
+			g.Groups[name] = values[i]
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+// FacetValue is one distinct value of a column and its number of rows.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets returns the distinct non-empty values of each column in columns
+// among the rows matching f (cursor ignored), most frequent first, by
+// column. f.Limit, if positive, bounds the values returned per column.
+// Levels are returned as their numbers.
+func Facets(db *sql.DB, f LogFilter, columns []string) (map[string][]FacetValue, error) {
+	out := make(map[string][]FacetValue, len(columns))
+	for _, name := range columns {
+		col, ok := groupColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot group by %q", name)
+		}
+
+		// Empty values are excluded before the limit applies.
+		where, args := f.whereClause()
+		q := `
+SELECT ` + col + ` AS v, COUNT(*) AS n
+FROM logs
+WHERE ` + strings.Join(append(where, col+" != ''"), "\n  AND ") + `
+GROUP BY v
+ORDER BY n DESC, v`
+		if f.Limit > 0 {
+			q += "\nLIMIT ?"
+			args = append(args, f.Limit)
+		}
+
+		values, err := facetValues(db, q, args)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = values
+	}
+	return out, nil
+}
+
+// facetValues runs a Facets query.
+func facetValues(db *sql.DB, q string, args []any) ([]FacetValue, error) {
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []FacetValue{}
+	for rows.Next() {
+		var v FacetValue
+		if err := rows.Scan(&v.Value, &v.Count); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,187 @@
+/*******************************************************************************
+*  internal/tlsutil/tlsutil.go
+*
+*  TLS configuration for the collector's HTTP server. Certificates are read
+*  from files and transparently reloaded when the files change (e.g. after a
+*  certificate renewal), and API clients may optionally be required to present
+*  a certificate signed by a configured CA.
+*******************************************************************************/
+
+package tlsutil
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*******************************************************************************
+*  TYPES
+*******************************************************************************/
+
+// Options configures ServerConfig.
+type Options struct {
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile, if set, enables mTLS: client certificates are verified
+	// against the CAs in this PEM file.
+	ClientCAFile string
+
+	// ClientAuth is "require" (default when ClientCAFile is set) to reject
+	// clients without a valid certificate, or "optional" to verify only
+	// certificates that are presented.
+	ClientAuth string
+}
+
+// CertReloader serves a certificate/key pair from disk, reloading it when
+// either file's modification time changes.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// checkInterval bounds how often the files are stat'ed.
+const checkInterval = time.Second
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// NewCertReloader loads the initial certificate, failing if it is invalid.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *CertReloader) reload() error {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("stat certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("stat key: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.certMod = certInfo.ModTime()
+	r.keyMod = keyInfo.ModTime()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. If the files changed
+// but cannot be loaded (e.g. a half-written renewal), the previous
+// certificate keeps being served.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= checkInterval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.reload(); err != nil {
+				log.Printf("TLS certificate reload failed, keeping previous: %v", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from %s", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *CertReloader) changed() bool {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return false
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return false
+	}
+	return !certInfo.ModTime().Equal(r.certMod) || !keyInfo.ModTime().Equal(r.keyMod)
+}
+
+// ServerConfig builds a TLS configuration from opts.
+func ServerConfig(opts Options) (*tls.Config, error) {
+	reloader, err := NewCertReloader(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA file %q: no PEM certificates found", opts.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+
+		switch opts.ClientAuth {
+		case "", "require":
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("unknown client auth mode %q (want require or optional)", opts.ClientAuth)
+		}
+	} else if opts.ClientAuth != "" {
+		return nil, fmt.Errorf("client auth mode %q requires a client CA file", opts.ClientAuth)
+	}
+
+	return cfg, nil
+}
+
+// RedirectHandler redirects every request to HTTPS on the host of the
+// request and the port of httpsAddr (e.g. ":8443"); port 443 is omitted.
+func RedirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]") // "[::1]" without a port
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // bare IPv6 literal
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
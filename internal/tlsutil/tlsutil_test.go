@@ -0,0 +1,178 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSigned writes a self-signed certificate and key for cn.
+func writeSelfSigned(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{cn},
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey failed: %v", err)
+	}
+
+	certFile = filepath.Join(dir, cn+".crt")
+	keyFile = filepath.Join(dir, cn+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertReloader_ReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "first")
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader failed: %v", err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf == nil || cert.Leaf.Subject.CommonName != "first" {
+		t.Fatalf("initial certificate = %v", cert.Leaf)
+	}
+
+	// Replace the pair and push the mtimes forward so the change is seen
+	// even on filesystems with coarse timestamps.
+	newCert, newKey := writeSelfSigned(t, dir, "second")
+	later := time.Now().Add(time.Minute)
+	for _, p := range [][2]string{{newCert, certFile}, {newKey, keyFile}} {
+		if err := os.Rename(p[0], p[1]); err != nil {
+			t.Fatal(err)
+		}
+		_ = os.Chtimes(p[1], later, later)
+	}
+	r.lastCheck = time.Time{}
+
+	cert, _ = r.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("certificate after change = %q, want second", cert.Leaf.Subject.CommonName)
+	}
+
+	// A broken replacement keeps the previous certificate.
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute))
+	r.lastCheck = time.Time{}
+
+	cert, _ = r.GetCertificate(nil)
+	if cert == nil || cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("broken reload replaced certificate")
+	}
+}
+
+func TestServerConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "localhost")
+	clientCert, clientKey := writeSelfSigned(t, dir, "client")
+
+	cfg, err := ServerConfig(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCert})
+	if err != nil {
+		t.Fatalf("ServerConfig failed: %v", err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("ClientAuth = %v, want RequireAndVerifyClientCert", cfg.ClientAuth)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	serverPEM, _ := os.ReadFile(certFile)
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(serverPEM)
+
+	get := func(certs []tls.Certificate) error {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs: roots, ServerName: "localhost", Certificates: certs,
+		}}}
+		resp, err := c.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	if err := get(nil); err == nil {
+		t.Errorf("request without client certificate succeeded")
+	}
+	pair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := get([]tls.Certificate{pair}); err != nil {
+		t.Errorf("request with client certificate failed: %v", err)
+	}
+}
+
+func TestServerConfig_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSigned(t, dir, "localhost")
+
+	if _, err := ServerConfig(Options{CertFile: certFile, KeyFile: keyFile, ClientAuth: "optional"}); err == nil {
+		t.Errorf("client auth without CA succeeded")
+	}
+	if _, err := ServerConfig(Options{CertFile: certFile, KeyFile: certFile}); err == nil {
+		t.Errorf("mismatched key succeeded")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	cases := []struct {
+		addr, host, want string
+	}{
+		{":8443", "logs.local:8080", "https://logs.local:8443/api/logs?x=1"},
+		{":443", "logs.local:8080", "https://logs.local/api/logs?x=1"},
+		{":8443", "[::1]", "https://[::1]:8443/api/logs?x=1"},
+		{":443", "[::1]", "https://[::1]/api/logs?x=1"},
+		{":443", "[::1]:8080", "https://[::1]/api/logs?x=1"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://"+tc.host+"/api/logs?x=1", nil)
+		w := httptest.NewRecorder()
+		RedirectHandler(tc.addr).ServeHTTP(w, r)
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tc.want {
+			t.Errorf("%s: got %d %q, want %q", tc.addr, w.Code, w.Header().Get("Location"), tc.want)
+		}
+	}
+}
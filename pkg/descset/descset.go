@@ -0,0 +1,76 @@
+/*******************************************************************************
+*  pkg/descset/descset.go
+*
+*  Self-describing envelopes. A publisher serializes the descriptors of the
+*  types it logs into a FileDescriptorSet, stamps every envelope with the set's
+*  fingerprint and pushes the set itself on the schema side-channel, so a
+*  collector that has never seen the types can still decode them.
+*
+*  The side-channel is a two-frame ZMQ message [Topic, set] sent on the same
+*  PUB socket as the envelopes.
+*******************************************************************************/
+
+package descset
+
+/*******************************************************************************
+*  IMPORTS
+*******************************************************************************/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+/*******************************************************************************
+*  CONSTANTS
+*******************************************************************************/
+
+// Topic is the topic frame of schema side-channel messages.
+const Topic = "protolog.schema"
+
+/*******************************************************************************
+*  FUNCTIONS
+*******************************************************************************/
+
+// Build serializes the files declaring msgs, together with their transitive
+// imports, into a FileDescriptorSet. Imports come before the files that use
+// them and the encoding is deterministic, so the same types always give the
+// same fingerprint.
+func Build(msgs ...protoreflect.MessageDescriptor) ([]byte, error) {
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("no message types given")
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	seen := make(map[string]bool)
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	for _, md := range msgs {
+		add(md.ParentFile())
+	}
+
+	return proto.MarshalOptions{Deterministic: true}.Marshal(&set)
+}
+
+// Fingerprint returns the fingerprint of a serialized FileDescriptorSet:
+// the lowercase hex SHA-256 of its bytes.
+func Fingerprint(set []byte) string {
+	sum := sha256.Sum256(set)
+	return hex.EncodeToString(sum[:])
+}
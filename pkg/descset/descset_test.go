@@ -0,0 +1,54 @@
+package descset
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/Espeer5/protolog/pkg/logproto/demo"
+	"github.com/Espeer5/protolog/pkg/logproto/logging"
+)
+
+func TestBuild(t *testing.T) {
+	b, err := Build((&demo.Metric{}).ProtoReflect().Descriptor(),
+		(&logging.LogEnvelope{}).ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	pos := make(map[string]int)
+	for i, f := range set.GetFile() {
+		if _, dup := pos[f.GetName()]; dup {
+			t.Fatalf("file %q appears twice", f.GetName())
+		}
+		pos[f.GetName()] = i
+	}
+	for _, name := range []string{"demo/demo_message.proto", "protolog/options.proto",
+		"logging/log_envelope.proto", "google/protobuf/timestamp.proto"} {
+		if _, ok := pos[name]; !ok {
+			t.Errorf("set is missing %q", name)
+		}
+	}
+	if pos["protolog/options.proto"] > pos["demo/demo_message.proto"] {
+		t.Errorf("import listed after the file that uses it")
+	}
+
+	again, _ := Build((&demo.Metric{}).ProtoReflect().Descriptor(),
+		(&logging.LogEnvelope{}).ProtoReflect().Descriptor())
+	if Fingerprint(b) != Fingerprint(again) {
+		t.Errorf("fingerprint is not stable")
+	}
+	if len(Fingerprint(b)) != 64 {
+		t.Errorf("Fingerprint = %q, want 64 hex digits", Fingerprint(b))
+	}
+
+	if _, err := Build(); err == nil {
+		t.Errorf("Build with no types succeeded")
+	}
+}
@@ -1,11 +1,20 @@
 from typing import Optional, Union
 
-from .client import ProtologClient, LogLevelLike
+from .client import (
+    ProtologClient,
+    LogLevelLike,
+    descriptor_set,
+    fingerprint,
+    load_curve_key,
+)
 from .protos.logging import log_envelope_pb2
 
 __all__ = [
     "ProtologClient",
+    "descriptor_set",
+    "fingerprint",
     "init_logging",
+    "load_curve_key",
     "log",
     "log_envelope_pb2",
 ]
@@ -21,6 +30,11 @@ def init_logging(
     host: Optional[str] = None,
     pid: Optional[int] = None,
     bind: bool = False,
+    topic_frame: bool = True,
+    curve_server_key: Optional[str] = None,
+    curve_public_key: Optional[str] = None,
+    curve_secret_key: Optional[str] = None,
+    push_schemas: bool = True,
 ) -> ProtologClient:
     """
     Initialize a global ProtologClient for simple usage.
@@ -39,6 +53,11 @@ def init_logging(
         host=host,
         pid=pid,
         bind=bind,
+        topic_frame=topic_frame,
+        curve_server_key=curve_server_key,
+        curve_public_key=curve_public_key,
+        curve_secret_key=curve_secret_key,
+        push_schemas=push_schemas,
     )
     return _client
 
@@ -1,9 +1,12 @@
+import hashlib
 import os
 import socket
 import threading
-from typing import Optional, Union
+import time
+from typing import Dict, Iterable, Optional, Tuple, Union
 
 import zmq
+from google.protobuf import descriptor_pb2
 from google.protobuf.message import Message
 from google.protobuf.timestamp_pb2 import Timestamp
 
@@ -11,6 +14,9 @@ from google.protobuf.timestamp_pb2 import Timestamp
 from .protos.logging import log_envelope_pb2
 
 
+# Topic frame of schema side-channel messages: [SCHEMA_TOPIC, FileDescriptorSet].
+SCHEMA_TOPIC = b"protolog.schema"
+
 LogLevelLike = Union[
     int,
     str,
@@ -54,6 +60,48 @@ def _resolve_level(
     return level
 
 
+def descriptor_set(messages: Iterable[Message]) -> bytes:
+    """
+    Serialize the files declaring the given messages (or message classes),
+    together with their transitive imports, into a FileDescriptorSet.
+    Imports come before the files that use them.
+    """
+    fds = descriptor_pb2.FileDescriptorSet()
+    seen = set()
+
+    def add(fd) -> None:
+        if fd.name in seen:
+            return
+        seen.add(fd.name)
+        for dep in fd.dependencies:
+            add(dep)
+        fdp = fds.file.add()
+        fd.CopyToProto(fdp)
+
+    for m in messages:
+        add(m.DESCRIPTOR.file)
+    return fds.SerializeToString(deterministic=True)
+
+
+def fingerprint(set_bytes: bytes) -> str:
+    """Fingerprint of a serialized FileDescriptorSet: hex SHA-256 of its bytes."""
+    return hashlib.sha256(set_bytes).hexdigest()
+
+
+def load_curve_key(path: str) -> str:
+    """
+    Read a Z85 CURVE key from a file written by `log-collector keygen`
+    (the first line that is not a "# ..." comment).
+    """
+    with open(path, "r", encoding="ascii") as f:
+        for line in f:
+            line = line.strip()
+            if not line or line == "#" or line.startswith("# "):
+                continue
+            return line.split()[0]
+    raise ValueError(f"no CURVE key found in {path!r}")
+
+
 class ProtologClient:
     """
     Simple publisher that wraps building LogEnvelope and sending it over ZMQ PUB.
@@ -73,6 +121,12 @@ class ProtologClient:
         msg = demo_message_pb2.Message(text="Hello", count=42)
         client.log("INFO", msg, summary="demo hello")
 
+    Envelopes carrying a protobuf Message are stamped with the fingerprint of
+    the message's descriptors, and the descriptors themselves are pushed on
+    the schema side-channel (on first use and then every
+    ``schema_push_interval`` seconds), so collectors learn types they were
+    not configured with. Pass ``push_schemas=False`` to disable this.
+
     """
 
     def __init__(
@@ -84,6 +138,12 @@ class ProtologClient:
         host: Optional[str] = None,
         pid: Optional[int] = None,
         bind: bool = False,
+        topic_frame: bool = True,
+        curve_server_key: Optional[str] = None,
+        curve_public_key: Optional[str] = None,
+        curve_secret_key: Optional[str] = None,
+        push_schemas: bool = True,
+        schema_push_interval: float = 30.0,
         zmq_context: Optional[zmq.Context] = None,
     ) -> None:
         self.endpoint = endpoint
@@ -92,6 +152,17 @@ class ProtologClient:
         self.host = host or socket.gethostname()
         self.pid = pid if pid is not None else os.getpid()
         self.bind = bind
+        # Prefix each envelope with a topic frame so the collector can filter
+        # subscriptions before decoding. Disable for collectors that predate
+        # topic frames.
+        self.topic_frame = topic_frame
+        # The schema side-channel needs multipart messages.
+        self.push_schemas = push_schemas and topic_frame
+        self.schema_push_interval = schema_push_interval
+        # type full name -> (descriptor set, fingerprint)
+        self._schemas: Dict[str, Tuple[bytes, str]] = {}
+        # fingerprint -> monotonic time of the last push
+        self._pushed: Dict[str, float] = {}
 
         self._ctx = zmq_context or zmq.Context.instance()
         self._sock = self._ctx.socket(zmq.PUB)
@@ -99,6 +170,18 @@ class ProtologClient:
         # It's often good to set a small high-water mark to avoid unbounded buffers
         self._sock.setsockopt(zmq.SNDHWM, 1000)
 
+        # CURVE client role for collector endpoints with the "curve" option.
+        # Keys are Z85 strings as produced by `log-collector keygen`.
+        if curve_server_key is not None:
+            if curve_public_key is None or curve_secret_key is None:
+                raise ValueError(
+                    "curve_public_key and curve_secret_key are required "
+                    "together with curve_server_key"
+                )
+            self._sock.curve_serverkey = curve_server_key.encode("ascii")
+            self._sock.curve_publickey = curve_public_key.encode("ascii")
+            self._sock.curve_secretkey = curve_secret_key.encode("ascii")
+
         if bind:
             self._sock.bind(endpoint)
         else:
@@ -169,6 +252,8 @@ class ProtologClient:
                 else:
                     env.type = payload.DESCRIPTOR.full_name
                 env.payload = payload.SerializeToString()
+                if self.push_schemas:
+                    env.schema_fingerprint = self._push_schema(payload)
             elif isinstance(payload, (bytes, bytearray, memoryview)):
                 if type_name is None:
                     raise ValueError(
@@ -186,5 +271,30 @@ class ProtologClient:
             env.summary = summary
 
             data = env.SerializeToString()
-            # Single-part PUB message; collector SUB has no topic frames
-            self._sock.send(data, 0)
+            if self.topic_frame:
+                self._sock.send_multipart([env.topic.encode("utf-8"), data])
+            else:
+                # Legacy single-part message
+                self._sock.send(data, 0)
+
+    def _push_schema(self, payload: Message) -> str:
+        """
+        Push the descriptors of payload's type if they have not been sent
+        recently, and return their fingerprint. Called with the lock held.
+        """
+        name = payload.DESCRIPTOR.full_name
+        entry = self._schemas.get(name)
+        if entry is None:
+            set_bytes = descriptor_set([payload])
+            entry = (set_bytes, fingerprint(set_bytes))
+            self._schemas[name] = entry
+        set_bytes, fp = entry
+
+        # PUB drops messages while no subscriber is connected, so sets are
+        # re-sent periodically rather than once.
+        now = time.monotonic()
+        last = self._pushed.get(fp)
+        if last is None or now - last >= self.schema_push_interval:
+            self._sock.send_multipart([SCHEMA_TOPIC, set_bytes])
+            self._pushed[fp] = now
+        return fp
@@ -11,6 +11,7 @@ import (
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"github.com/Espeer5/protolog/pkg/descset"
 	"github.com/Espeer5/protolog/pkg/logproto/demo"
 	"github.com/Espeer5/protolog/pkg/logproto/logging"
 )
@@ -62,12 +63,36 @@ func main() {
 		{name: "audit", level: logging.LogLevel_LOG_LEVEL_INFO, typeName: "demo.AuditEvent"},
 	}
 
+	// Descriptors of every demo type, pushed on the schema side-channel so
+	// collectors without demo descriptors can still decode the payloads.
+	schema, err := descset.Build(
+		(&demo.Message{}).ProtoReflect().Descriptor(),
+		(&demo.Metric{}).ProtoReflect().Descriptor(),
+		(&demo.Alert{}).ProtoReflect().Descriptor(),
+		(&demo.AuditEvent{}).ProtoReflect().Descriptor(),
+	)
+	if err != nil {
+		log.Fatalf("failed to build descriptor set: %v", err)
+	}
+	fingerprint := descset.Fingerprint(schema)
+
 	log.Println("Test publisher started on tcp://*:5556")
 	time.Sleep(500 * time.Millisecond) // allow SUB to connect
 
 	seq := 0
+	var lastPush time.Time
 
 	for {
+		// PUB drops messages while no subscriber is connected, so the set is
+		// re-sent periodically rather than once.
+		if time.Since(lastPush) >= 30*time.Second {
+			if _, err := pub.SendMessage(descset.Topic, schema); err != nil {
+				log.Printf("send descriptor set error: %v\n", err)
+			} else {
+				lastPush = time.Now()
+			}
+		}
+
 		for _, t := range topics {
 			host := hosts[rand.Intn(len(hosts))]
 			service := services[rand.Intn(len(services))]
@@ -85,7 +110,8 @@ func main() {
 				Type:    t.typeName,
 				Payload: payload,
 
-				Summary: summary,
+				Summary:           summary,
+				SchemaFingerprint: fingerprint,
 			}
 
 			data, err := proto.Marshal(env)
@@ -94,6 +120,12 @@ func main() {
 				continue
 			}
 
+			// [topic, envelope]: the topic frame lets the collector filter
+			// subscriptions before decoding.
+			if _, err := pub.SendBytes([]byte(t.name), zmq4.SNDMORE); err != nil {
+				log.Printf("send topic frame error for topic %q: %v\n", t.name, err)
+				continue
+			}
 			if _, err := pub.SendBytes(data, 0); err != nil {
 				log.Printf("send error for topic %q: %v\n", t.name, err)
 				continue
@@ -1,5 +1,5 @@
 import { useEffect, useRef, useState } from 'react'
-import type { LogDTO, TopicsResponse } from './types'
+import type { FacetsResponse, LogDTO, TopicsResponse } from './types'
 import { formatTimestamp } from './utils/time'
 import './App.css'
 
@@ -15,6 +15,7 @@ function App() {
   // Raw data
   const [logs, setLogs] = useState<LogDTO[]>([])
   const [knownTopics, setKnownTopics] = useState<string[]>([])
+  const [facets, setFacets] = useState<FacetsResponse | null>(null)
   const MAX_LIVE_LOGS = 5000
 
   // Selection / filters
@@ -58,22 +59,28 @@ function App() {
   ).sort()
 
   const topicOptions = Array.from(
-    new Set([...(knownTopics || []), ...allTopicsFromLogs]),
+    new Set([...(knownTopics || []), ...(facets?.topics ?? []), ...allTopicsFromLogs]),
   )
     .filter(Boolean)
     .sort()
 
   const hostOptions = Array.from(
-    new Set(logs.map((l) => l.host).filter(Boolean)),
-  ).sort()
+    new Set([...(facets?.hosts ?? []), ...logs.map((l) => l.host)]),
+  )
+    .filter(Boolean)
+    .sort()
 
   const levelOptions = Array.from(
-    new Set(logs.map((l) => l.level).filter(Boolean)),
-  ).sort()
+    new Set([...Object.keys(facets?.levels ?? {}), ...logs.map((l) => l.level)]),
+  )
+    .filter(Boolean)
+    .sort()
 
   const typeOptions = Array.from(
-    new Set(logs.map((l) => l.type).filter(Boolean)),
-  ).sort()
+    new Set([...(facets?.types ?? []), ...logs.map((l) => l.type)]),
+  )
+    .filter(Boolean)
+    .sort()
 
   function toggleValue(
     value: string,
@@ -90,8 +97,10 @@ function App() {
   const [serviceFilter, setServiceFilter] = useState<string[]>([])
 
   const serviceOptions = Array.from(
-    new Set(logs.map((l) => l.service).filter(Boolean)),
-  ).sort()
+    new Set([...(facets?.services ?? []), ...logs.map((l) => l.service)]),
+  )
+    .filter(Boolean)
+    .sort()
 
   function passesFilters(log: LogDTO): boolean {
     if (topicFilter.length && !topicFilter.includes(log.topic)) return false
@@ -152,6 +161,7 @@ function App() {
       setHistoryHasMore(Boolean(data.next_cursor))
 
       setLogs((prev) => (reset ? data.items : [...prev, ...data.items]))
+      if (reset) fetchFacets()
       setSelectedLog(null)
       setHasNewLogs(false)
     } catch (err) {
@@ -177,6 +187,26 @@ function App() {
     }
   }
 
+  // --- Fetch filter values stored in the history time range ---
+
+  async function fetchFacets() {
+    try {
+      const params = new URLSearchParams()
+      params.set('start', new Date(startTime).toISOString())
+      params.set('end', new Date(endTime).toISOString())
+      const res = await fetch(`/api/facets?${params.toString()}`)
+      if (!res.ok) throw new Error(`HTTP ${res.status}`)
+      setFacets((await res.json()) as FacetsResponse)
+    } catch (err: any) {
+      console.error('Failed to fetch facets:', err)
+    }
+  }
+
+  function refreshFilters() {
+    fetchTopics()
+    fetchFacets()
+  }
+
   // --- WebSocket connection: subscribe to ALL topics (no filter) ---
 
   useEffect(() => {
@@ -242,9 +272,9 @@ function App() {
     }
   }, [filteredLogs, paused])
 
-  // Initial topics load
+  // Initial topics and filter values load
   useEffect(() => {
-    fetchTopics()
+    refreshFilters()
   }, [])
 
   // --- Controls ---
@@ -324,7 +354,7 @@ function App() {
         <aside>
           <div className="aside-header">
             <h2>Filters</h2>
-            <button onClick={fetchTopics}>Refresh</button>
+            <button onClick={refreshFilters}>Refresh</button>
           </div>
 
           <div className="filter-section">
@@ -588,11 +618,17 @@ function App() {
                     </span>
                   </div>
 
-                  <div className="detail-payload-label">Payload</div>
+                  <div className="detail-payload-label">
+                    Payload
+                    {selectedLog.payloadHeuristic &&
+                      ' (heuristic wire decoding, type unknown)'}
+                  </div>
                   <pre className="detail-payload">
-                    {selectedLog.payloadJson
-                      ? JSON.stringify(selectedLog.payloadJson, null, 2)
-                      : '// no payload or unknown type'}
+                    {selectedLog.payloadText
+                      ? selectedLog.payloadText
+                      : selectedLog.payloadJson
+                        ? JSON.stringify(selectedLog.payloadJson, null, 2)
+                        : '// no payload or unknown type'}
                   </pre>
                 </div>
               ) : (
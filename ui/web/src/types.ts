@@ -1,4 +1,6 @@
 export interface LogDTO {
+  // stored row id (history only); see /api/logs/{id}/context
+  id?: number
   topic: string
   timestamp: string
   level: string
@@ -7,13 +9,30 @@ export interface LogDTO {
   summary: string
   type: string
   payloadJson?: any
+  // payloadJson is a schema-less wire-format decoding (type unknown)
+  payloadHeuristic?: boolean
+  // set instead of payloadJson when a text or flat format was requested
+  payloadText?: string
+  payloadFormat?: string
 }
 
 export interface TopicsResponse {
   topics: string[]
 }
 
+// Distinct values stored in a time range, from /api/facets
+export interface FacetsResponse {
+  topics: string[]
+  services: string[]
+  hosts: string[]
+  types: string[]
+  levels: Record<string, number>
+}
+
 export type LogsQueryResponse = {
   items: LogDTO[]
   next_cursor?: string
+  // pass as before/after to page to older/newer rows
+  before_cursor?: string
+  after_cursor?: string
 }